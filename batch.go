@@ -0,0 +1,36 @@
+package genserver
+
+import "net/rpc"
+
+// Outcome of a single request of `CallAll`
+type Result struct {
+	Value any
+	Error error
+}
+
+// Sends independent requests to `s` and waits for all of them. Results are in the order of `reqs`.
+// At most `concurrency` requests are outstanding at a time, zero means no limit.
+// `Seq` of the requests is ignored, it's assigned by the server process
+func CallAll(s GenServer, reqs []Request, concurrency int) []Result {
+	replies := make([]any, len(reqs))
+	calls := make([]*rpc.Call, len(reqs))
+	done := make(chan *rpc.Call, max(len(reqs), 1))
+	outstanding := 0
+	for i, req := range reqs {
+		if concurrency > 0 && outstanding == concurrency {
+			<-done
+			outstanding--
+		}
+		// cast only to not block on each reply
+		calls[i] = castMode(s, req.ServiceMethod, req.Body, &replies[i], done, req.Meta, ModeCall)
+		outstanding++
+	}
+	for ; outstanding > 0; outstanding-- {
+		<-done
+	}
+	results := make([]Result, len(reqs))
+	for i, call := range calls {
+		results[i] = Result{Value: replies[i], Error: call.Error}
+	}
+	return results
+}
@@ -0,0 +1,54 @@
+package genserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallAll(t *testing.T) {
+	t.Run("should return results in order of requests", func(t *testing.T) {
+		// arrange
+		s := NewLazyStoreServer(WithKnownMethods([]string{"get", "put"}))
+		defer s.Close()
+		s.Call("put", KeyValuePair{"one", 1}, nil)
+		s.Call("put", KeyValuePair{"two", 2}, nil)
+		s.Call("put", KeyValuePair{"three", 3}, nil)
+
+		// act
+		results := CallAll(s, []Request{
+			{ServiceMethod: "get", Body: "three"},
+			{ServiceMethod: "get", Body: "one"},
+			{ServiceMethod: "scan", Body: nil},
+			{ServiceMethod: "get", Body: "two"},
+		}, 0)
+
+		// assert
+		assert.Equal(t, []Result{
+			{Value: 3},
+			{Value: 1},
+			{Error: ErrUnknownMethod},
+			{Value: 2},
+		}, results)
+	})
+
+	t.Run("should bound number of outstanding requests", func(t *testing.T) {
+		// arrange
+		s := NewLazyStoreServer()
+		defer s.Close()
+		s.Call("put", KeyValuePair{"one", 1}, nil)
+		reqs := make([]Request, 10)
+		for i := range reqs {
+			reqs[i] = Request{ServiceMethod: "get", Body: "one"}
+		}
+
+		// act
+		results := CallAll(s, reqs, 3)
+
+		// assert
+		for _, r := range results {
+			assert.Nil(t, r.Error)
+			assert.Equal(t, 1, r.Value)
+		}
+	})
+}
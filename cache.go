@@ -0,0 +1,165 @@
+package genserver
+
+import (
+	"context"
+	"net/rpc"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Wraps `s` with a client-side cache of `get` replies kept for `ttl`. A cached reply is served without
+// a round trip to the server process, so it may be stale within `ttl`. The cache is invalidated by `put`
+// and `delete` requests sent through the wrapper by any of its methods, changes made by other clients are not observed.
+// Only `Call` reads from the cache, `get` arguments must be comparable. Entries expire by the `Clock` of `s`.
+// Each caller gets its own deep copy of a cached reply, see `WithReplyIsolation`
+func WithReadCache(s GenServer, ttl time.Duration) GenServer {
+	return &readCache{GenServer: s, ttl: ttl, clock: clockOf(s), entries: make(map[any]cacheEntry)}
+}
+
+type readCache struct {
+	GenServer
+	ttl        time.Duration
+	clock      Clock
+	mu         sync.Mutex
+	entries    map[any]cacheEntry
+	generation uint64 // bumped on invalidation, so replies of gets sent before it aren't cached
+}
+
+type cacheEntry struct {
+	value     any
+	expiresAt time.Time
+}
+
+func (c *readCache) Cast(serviceMethod string, args any, reply any, done chan *rpc.Call) *rpc.Call {
+	c.invalidate(serviceMethod)
+	return c.GenServer.Cast(serviceMethod, args, reply, done)
+}
+
+func (c *readCache) CastAck(serviceMethod string, args any) error {
+	c.invalidate(serviceMethod)
+	return c.GenServer.CastAck(serviceMethod, args)
+}
+
+func (c *readCache) CastReliable(serviceMethod string, args any) error {
+	c.invalidate(serviceMethod)
+	return c.GenServer.CastReliable(serviceMethod, args)
+}
+
+func (c *readCache) CastCallback(serviceMethod string, args any, reply any, cb func(*rpc.Call)) {
+	c.invalidate(serviceMethod)
+	c.GenServer.CastCallback(serviceMethod, args, reply, cb)
+}
+
+func (c *readCache) CastWithMeta(serviceMethod string, args any, reply any, done chan *rpc.Call, meta Meta) *rpc.Call {
+	c.invalidate(serviceMethod)
+	return c.GenServer.CastWithMeta(serviceMethod, args, reply, done, meta)
+}
+
+func (c *readCache) castMode(serviceMethod string, args any, reply any, done chan *rpc.Call, meta Meta, mode Mode) *rpc.Call {
+	c.invalidate(serviceMethod)
+	return castMode(c.GenServer, serviceMethod, args, reply, done, meta, mode)
+}
+
+// The request is sent through the wrapper once `d` has passed, so it invalidates the cache then
+func (c *readCache) SendAfter(d time.Duration, serviceMethod string, args any) func() bool {
+	return sendAfter(c, c.clock, d, serviceMethod, args)
+}
+
+func (c *readCache) CallInto(serviceMethod string, args any, reply any) error {
+	c.invalidate(serviceMethod)
+	return c.GenServer.CallInto(serviceMethod, args, reply)
+}
+
+func (c *readCache) CallFull(serviceMethod string, args any, reply any) (*rpc.Call, error) {
+	c.invalidate(serviceMethod)
+	return c.GenServer.CallFull(serviceMethod, args, reply)
+}
+
+func (c *readCache) CallWithMeta(serviceMethod string, args any, reply any, meta Meta) error {
+	c.invalidate(serviceMethod)
+	return c.GenServer.CallWithMeta(serviceMethod, args, reply, meta)
+}
+
+func (c *readCache) CallContext(ctx context.Context, serviceMethod string, args any, reply any) error {
+	c.invalidate(serviceMethod)
+	return c.GenServer.CallContext(ctx, serviceMethod, args, reply)
+}
+
+func (c *readCache) CallTimeout(serviceMethod string, args any, reply any, timeout time.Duration) error {
+	c.invalidate(serviceMethod)
+	return c.GenServer.CallTimeout(serviceMethod, args, reply, timeout)
+}
+
+func (c *readCache) CallTimed(serviceMethod string, args any, reply any) (time.Duration, error) {
+	c.invalidate(serviceMethod)
+	return c.GenServer.CallTimed(serviceMethod, args, reply)
+}
+
+func (c *readCache) CallWithProgress(serviceMethod string, args any) (<-chan Progress, func() (any, error)) {
+	c.invalidate(serviceMethod)
+	return c.GenServer.CallWithProgress(serviceMethod, args)
+}
+
+func (c *readCache) Call(serviceMethod string, args any, reply any) error {
+	c.invalidate(serviceMethod)
+	if serviceMethod != "get" || args == nil || !reflect.TypeOf(args).Comparable() || reply == nil {
+		return c.GenServer.Call(serviceMethod, args, reply)
+	}
+	vreply := reflect.ValueOf(reply)
+	if vreply.Kind() != reflect.Pointer {
+		return c.GenServer.Call(serviceMethod, args, reply)
+	}
+	c.mu.Lock()
+	entry, ok := c.entries[args]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && c.clock.Now().Before(entry.expiresAt) && reflect.TypeOf(entry.value).AssignableTo(vreply.Type().Elem()) {
+		vreply.Elem().Set(reflect.ValueOf(clone(entry.value)))
+		return nil
+	}
+	if err := c.GenServer.Call(serviceMethod, args, reply); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		// a copy, the caller keeps using `reply`
+		c.entries[args] = cacheEntry{value: clone(vreply.Elem().Interface()), expiresAt: c.clock.Now().Add(c.ttl)}
+	}
+	return nil
+}
+
+func (c *readCache) invalidate(serviceMethod string) {
+	if serviceMethod != "put" && serviceMethod != "delete" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+	c.generation++
+}
+
+// Returns a getter of the reply of `serviceMethod`, e.g. the state of the behaviour for a dashboard.
+// The reply is fetched on the first use and then at most once per `ttl`, in between the cached one is returned.
+// If a fetch fails the last reply is kept, the zero value before the first successful fetch.
+// The reply expires by the `Clock` of `s`
+func ReplicaView[T any](s GenServer, serviceMethod string, ttl time.Duration) func() T {
+	clock := clockOf(s)
+	var mu sync.Mutex
+	var value T
+	var expiresAt time.Time
+	return func() T {
+		mu.Lock()
+		defer mu.Unlock()
+		if clock.Now().Before(expiresAt) {
+			return value
+		}
+		var reply T
+		if err := s.Call(serviceMethod, nil, &reply); err == nil {
+			value = reply
+		}
+		expiresAt = clock.Now().Add(ttl)
+		return value
+	}
+}
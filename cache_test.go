@@ -0,0 +1,155 @@
+package genserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadCache(t *testing.T) {
+	t.Run("should serve repeated get from cache within ttl", func(t *testing.T) {
+		// arrange
+		s := NewLazyStoreServer()
+		defer s.Close()
+		cache := WithReadCache(s, time.Minute)
+		cache.Call("put", KeyValuePair{"one", 1}, nil)
+
+		// act
+		var v, v2 int
+		err := cache.Call("get", "one", &v)
+		err2 := cache.Call("get", "one", &v2)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, err2)
+		assert.Equal(t, 1, v)
+		assert.Equal(t, 1, v2)
+		assert.Equal(t, uint64(2), s.Stats().Handled)
+	})
+
+	t.Run("should invalidate cache on put", func(t *testing.T) {
+		// arrange
+		s := NewLazyStoreServer()
+		defer s.Close()
+		cache := WithReadCache(s, time.Minute)
+		cache.Call("put", KeyValuePair{"one", 1}, nil)
+		var v int
+		cache.Call("get", "one", &v)
+
+		// act
+		<-cache.Cast("put", KeyValuePair{"one", 2}, nil, nil).Done
+		err := cache.Call("get", "one", &v)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 2, v)
+		assert.Equal(t, uint64(4), s.Stats().Handled)
+	})
+
+	t.Run("should invalidate cache on put sent by any method", func(t *testing.T) {
+		// arrange
+		s := NewLazyStoreServer()
+		defer s.Close()
+		cache := WithReadCache(s, time.Minute)
+		cache.Call("put", KeyValuePair{"one", 1}, nil)
+		var v, v2 int
+		cache.Call("get", "one", &v)
+
+		// act
+		cache.CallTimeout("put", KeyValuePair{"one", 2}, nil, time.Second)
+		cache.Call("get", "one", &v)
+		cache.CastAck("put", KeyValuePair{"one", 3})
+		cache.Call("get", "one", &v2)
+
+		// assert
+		assert.Equal(t, 2, v)
+		assert.Equal(t, 3, v2)
+	})
+
+	t.Run("should not let callers share cached reply", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *ListServer {
+			return &ListServer{GenServer: genserv}
+		})
+		defer s.Close()
+		cache := WithReadCache(s, time.Minute)
+		var v, v2 []int
+		cache.Call("get", "list", &v)
+
+		// act
+		v[0] = -1
+		cache.Call("get", "list", &v2)
+
+		// assert
+		assert.Equal(t, []int{1, 2}, v2)
+		assert.Equal(t, uint64(1), s.Stats().Handled)
+	})
+
+	t.Run("should get from server process after ttl", func(t *testing.T) {
+		// arrange
+		s := NewLazyStoreServer()
+		defer s.Close()
+		cache := WithReadCache(s, 10*time.Millisecond)
+		cache.Call("put", KeyValuePair{"one", 1}, nil)
+		var v int
+		cache.Call("get", "one", &v)
+
+		// act
+		time.Sleep(20 * time.Millisecond)
+		err := cache.Call("get", "one", &v)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(3), s.Stats().Handled)
+	})
+	t.Run("should expire cached reply by clock of server process", func(t *testing.T) {
+		// arrange
+		clock := NewFakeClock()
+		s := NewLazyStoreServer(WithClock(clock))
+		defer s.Close()
+		cache := WithReadCache(s, time.Minute)
+		cache.Call("put", KeyValuePair{"one", 1}, nil)
+		var v int
+		cache.Call("get", "one", &v)
+
+		// act
+		clock.Advance(time.Minute)
+		err := cache.Call("get", "one", &v)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(3), s.Stats().Handled)
+	})
+}
+
+func TestReplicaView(t *testing.T) {
+	t.Run("should fetch reply again once expired by clock of server process", func(t *testing.T) {
+		// arrange
+		clock := NewFakeClock()
+		s := NewLazyStoreServer(WithClock(clock))
+		defer s.Close()
+		s.Call("put", KeyValuePair{"one", 1}, nil)
+		view := ReplicaView[int](s, "get", time.Minute)
+		view()
+
+		// act
+		view()
+		handledBefore := s.Stats().Handled
+		clock.Advance(time.Minute)
+		view()
+
+		// assert
+		assert.Equal(t, uint64(2), handledBefore)
+		assert.Equal(t, uint64(3), s.Stats().Handled)
+	})
+}
+
+// Replies with a fresh list on every request
+type ListServer struct {
+	GenServer
+}
+
+func (s *ListServer) Handle(string, uint64, any) (any, error) {
+	return []int{1, 2}, nil
+}
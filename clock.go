@@ -0,0 +1,42 @@
+package genserver
+
+import "time"
+
+// Source of time of a server process: request timestamps, uptime and call timeouts.
+// Replace it with a fake one via `WithClock` to test timing without real delays
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	// Calls `f` in its own goroutine after `d`. `stop` prevents the call and releases the timer,
+	// it reports whether the call was prevented
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// Returns a channel that's closed after `d` and the function that stops the timer, to be called on every path
+func timer(clock Clock, d time.Duration) (<-chan struct{}, func() bool) {
+	fired := make(chan struct{})
+	stop := clock.AfterFunc(d, func() { close(fired) })
+	return fired, stop
+}
+
+// Returns the clock of the server process `s`, the real clock if it has none, e.g. a remote server process
+func clockOf(s GenServer) Clock {
+	if serv, ok := serverOf(s); ok {
+		return serv.codec.options.clock
+	}
+	return realClock{}
+}
@@ -0,0 +1,179 @@
+package genserver
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClock(t *testing.T) {
+	t.Run("should time out call without real delay", func(t *testing.T) {
+		// arrange
+		clock := NewFakeClock()
+		s := Build(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		}, WithClock(clock))
+		defer s.Close()
+
+		// act
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.CallTimeout("echo", "foo", nil, time.Hour)
+		}()
+		<-clock.waiters
+		clock.Advance(time.Hour)
+
+		// assert
+		assert.ErrorIs(t, <-errs, ErrCallTimeout)
+	})
+
+	t.Run("should return reply before timeout", func(t *testing.T) {
+		// arrange
+		clock := NewFakeClock()
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		}, WithClock(clock))
+		defer s.Close()
+
+		// act
+		var reply string
+		err := s.CallTimeout("echo", "foo", &reply, time.Hour)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "foo", reply)
+	})
+
+	t.Run("should stop timers once call and handler complete", func(t *testing.T) {
+		// arrange
+		clock := NewFakeClock()
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		}, WithClock(clock), WithHandlerWatchdog(time.Hour, func(string, uint64) {}),
+			WithMethodTimeout(map[string]time.Duration{"echo": time.Hour}))
+		defer s.Close()
+
+		// act
+		err := s.CallTimeout("echo", "foo", nil, time.Hour)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Zero(t, clock.pending())
+	})
+
+	t.Run("should measure uptime by the clock", func(t *testing.T) {
+		// arrange
+		clock := NewFakeClock()
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		}, WithClock(clock))
+		defer s.Close()
+		s.Call("echo", nil, nil) // the server process has started
+
+		// act
+		clock.Advance(time.Minute)
+
+		// assert
+		assert.Equal(t, time.Minute, s.Stats().Uptime)
+	})
+}
+
+func TestMessageTTL(t *testing.T) {
+	t.Run("should fail stale requests and handle fresh ones", func(t *testing.T) {
+		// arrange
+		clock := NewFakeClock()
+		s := Build(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		}, WithClock(clock), WithMessageTTL(time.Minute))
+		defer s.Close()
+
+		// act
+		stale := s.Cast("echo", "stale", nil, nil)
+		clock.Advance(2 * time.Minute)
+		var reply string
+		fresh := s.Cast("echo", "fresh", &reply, nil)
+		s.Start()
+		<-stale.Done
+		<-fresh.Done
+
+		// assert
+		assert.ErrorIs(t, stale.Error, ErrRequestExpired)
+		assert.Nil(t, fresh.Error)
+		assert.Equal(t, "fresh", reply)
+	})
+}
+
+// Clock that only moves when `Advance` is called
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer
+	waiters chan struct{} // receives a value each time a timer is started, unless enough are waiting already
+}
+
+type fakeTimer struct {
+	deadline time.Time
+	f        func()
+}
+
+func NewFakeClock() *FakeClock {
+	return &FakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), waiters: make(chan struct{}, 16)}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.AfterFunc(d, func() { ch <- c.Now() })
+	return ch
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) func() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &fakeTimer{deadline: c.now.Add(d), f: f}
+	c.timers = append(c.timers, timer)
+	select {
+	case c.waiters <- struct{}{}:
+	default:
+	}
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, t := range c.timers {
+			if t == timer {
+				c.timers = append(c.timers[:i], c.timers[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.deadline.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		go timer.f()
+	}
+	c.timers = pending
+}
+
+// Number of timers that are neither fired nor stopped
+func (c *FakeClock) pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
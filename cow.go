@@ -0,0 +1,39 @@
+package genserver
+
+import "sync/atomic"
+
+// Immutable state of a server process, see `WithCopyOnWriteState`
+type cowState struct {
+	current atomic.Pointer[any]
+	apply   func(state any, serviceMethod string, body any) (any, any, error)
+}
+
+func newCowState(initial any, apply func(state any, serviceMethod string, body any) (any, any, error)) *cowState {
+	if apply == nil {
+		return nil
+	}
+	c := &cowState{apply: apply}
+	c.current.Store(&initial)
+	return c
+}
+
+// Applies the request on the listen loop and publishes the new state, unless it fails
+func (c *cowState) handle(req request) (any, error) {
+	state, reply, err := c.apply(c.load(), req.serviceMethod, req.body)
+	if err != nil {
+		return nil, err
+	}
+	c.current.Store(&state)
+	return reply, nil
+}
+
+func (c *cowState) load() any {
+	if c == nil {
+		return nil
+	}
+	return *c.current.Load()
+}
+
+func (s *genServer) LoadState() any {
+	return s.codec.cow.load()
+}
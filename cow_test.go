@@ -0,0 +1,109 @@
+package genserver
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyOnWriteState(t *testing.T) {
+	t.Run("should read consistent state while writes go through the listen loop", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *CowServer {
+			return &CowServer{GenServer: genserv}
+		}, WithCopyOnWriteState(map[string]int{}, applyCounters))
+		defer s.Close()
+
+		// act
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					s.Call("put", strconv.Itoa(j), nil)
+				}
+			}()
+		}
+		var inconsistent atomic.Bool
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					counters := s.LoadState().(map[string]int)
+					total := 0
+					for _, v := range counters {
+						total += v
+					}
+					if total != counters["total"]*2 {
+						inconsistent.Store(true)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		counters := s.LoadState().(map[string]int)
+
+		// assert
+		assert.False(t, inconsistent.Load())
+		assert.Equal(t, 1000, counters["total"])
+		assert.Equal(t, 10, counters["42"])
+	})
+
+	t.Run("should keep state if request fails", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *CowServer {
+			return &CowServer{GenServer: genserv}
+		}, WithCopyOnWriteState(map[string]int{}, applyCounters))
+		defer s.Close()
+		s.Call("put", "foo", nil)
+
+		// act
+		err := s.Call("clear", nil, nil)
+
+		// assert
+		assert.ErrorIs(t, err, errUnsupported)
+		assert.Equal(t, map[string]int{"foo": 1, "total": 1}, s.LoadState())
+	})
+
+	t.Run("should return nil if option is not set", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+
+		// act
+		state := s.LoadState()
+
+		// assert
+		assert.Nil(t, state)
+	})
+}
+
+var errUnsupported = errors.New("unsupported")
+
+// Counts puts of every key and their total, both change in one write
+func applyCounters(state any, serviceMethod string, body any) (any, any, error) {
+	if serviceMethod != "put" {
+		return nil, nil, fmt.Errorf("%w: %s", errUnsupported, serviceMethod)
+	}
+	counters := maps.Clone(state.(map[string]int))
+	counters[body.(string)]++
+	counters["total"]++
+	return counters, nil, nil
+}
+
+// Its requests are handled by the function set by `WithCopyOnWriteState`
+type CowServer struct {
+	GenServer
+}
+
+func (s *CowServer) Handle(string, uint64, any) (any, error) {
+	return nil, errUnsupported
+}
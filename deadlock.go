@@ -0,0 +1,31 @@
+package genserver
+
+// Checks the progress of the listen loop every `deadlockThreshold` until the server process terminates
+func (c *genServerCodec) detectDeadlocks() {
+	last := c.progress.Load()
+	reported := false
+	for {
+		fired, stop := timer(c.options.clock, c.options.deadlockThreshold)
+		select {
+		case <-c.done:
+			stop()
+			return
+		case <-fired:
+		}
+		current := c.progress.Load()
+		if current != last {
+			last, reported = current, false
+			continue
+		}
+		queued := c.requests.len()
+		if reported || queued == 0 || c.gate.isPaused() {
+			continue
+		}
+		reported = true
+		var serviceMethod string
+		if p := c.handling.Load(); p != nil {
+			serviceMethod = *p
+		}
+		c.options.onDeadlock(serviceMethod, queued)
+	}
+}
@@ -0,0 +1,54 @@
+package genserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadlockDetector(t *testing.T) {
+	type deadlock struct {
+		serviceMethod string
+		queued        int
+	}
+
+	t.Run("should report stalled loop with waiting requests", func(t *testing.T) {
+		// arrange
+		deadlocks := make(chan deadlock, 2)
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv, delay: 300 * time.Millisecond}
+		}, WithDeadlockDetector(50*time.Millisecond, func(serviceMethod string, queued int) {
+			deadlocks <- deadlock{serviceMethod, queued}
+		}))
+		defer s.Close()
+
+		// act
+		s.Cast("blocked", nil, nil, nil)
+		s.Cast("echo", nil, nil, nil)
+
+		// assert
+		select {
+		case d := <-deadlocks:
+			assert.Equal(t, deadlock{"blocked", 1}, d)
+		case <-time.After(time.Second):
+			t.Fatal("deadlock must be reported")
+		}
+	})
+
+	t.Run("should not report idle loop", func(t *testing.T) {
+		// arrange
+		deadlocks := make(chan deadlock, 1)
+		s := NewEchoServer(0, WithDeadlockDetector(20*time.Millisecond, func(serviceMethod string, queued int) {
+			deadlocks <- deadlock{serviceMethod, queued}
+		}))
+		defer s.Close()
+
+		// act
+		s.Call("echo", nil, nil)
+		time.Sleep(100 * time.Millisecond)
+
+		// assert
+		assert.Empty(t, deadlocks)
+	})
+}
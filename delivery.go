@@ -0,0 +1,86 @@
+package genserver
+
+import (
+	"errors"
+	"log"
+	"net/rpc"
+)
+
+// Returned by `GenServer.CastReliable` when no journal is set by `WithDeliveryJournal`
+var ErrNoDeliveryJournal = errors.New("delivery journal is not set")
+
+// Cast persisted by `GenServer.CastReliable` and not yet acknowledged
+type PendingCast struct {
+	ID            uint64
+	ServiceMethod string
+	Args          any
+}
+
+// Write-ahead log of casts sent by `GenServer.CastReliable`, e.g. backed by a file or a database.
+// Must be safe for concurrent use
+type DeliveryJournal interface {
+	// Persists the cast before it's put into the mailbox and returns its unique id
+	Append(serviceMethod string, args any) (uint64, error)
+	// Removes the cast once it has been answered successfully, or rejected before reaching the mailbox
+	Ack(id uint64) error
+	// Returns the casts that have not been acknowledged, in the order they were appended
+	Pending() ([]PendingCast, error)
+}
+
+func (s *genServer) CastReliable(serviceMethod string, args any) error {
+	journal := s.codec.options.deliveryJournal
+	if journal == nil {
+		return ErrNoDeliveryJournal
+	}
+	id, err := journal.Append(serviceMethod, args)
+	if err != nil {
+		return err
+	}
+	if err := s.castReliable(PendingCast{ID: id, ServiceMethod: serviceMethod, Args: args}); err != nil {
+		// rejected before reaching the mailbox, the caller gets the error instead of a replay behind its back
+		if ackErr := journal.Ack(id); ackErr != nil {
+			log.Print(ackErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *genServer) castReliable(pending PendingCast) error {
+	env := &envelope{args: pending.Args, cast: true, acked: true, ackID: pending.ID}
+	// `rpc.Client` writes the request on the caller's goroutine
+	call := s.client.Go(pending.ServiceMethod, env, nil, make(chan *rpc.Call, 1))
+	if env.enqueued {
+		return nil
+	}
+	<-call.Done
+	return env.error(call.Error)
+}
+
+// Sends the casts left unacknowledged by the previous incarnation of the server process
+func (s *genServer) replayPending() {
+	journal := s.codec.options.deliveryJournal
+	if journal == nil {
+		return
+	}
+	pending, err := journal.Pending()
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	for _, p := range pending {
+		if err := s.castReliable(p); err != nil {
+			return // the server process is closing, the rest stays in the journal
+		}
+	}
+}
+
+// Acknowledges a reliable cast answered successfully, whether handled, memoized, joined to a flight or forwarded
+func (c *genServerCodec) ack(req request) {
+	if req.env == nil || !req.env.acked {
+		return
+	}
+	if err := c.options.deliveryJournal.Ack(req.env.ackID); err != nil {
+		log.Print(err)
+	}
+}
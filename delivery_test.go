@@ -0,0 +1,144 @@
+package genserver
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCastReliable(t *testing.T) {
+	t.Run("should replay unacknowledged casts on restart", func(t *testing.T) {
+		// arrange
+		journal := NewMemoryJournal()
+		crashed := Build(func(genserv GenServer) *LazyStoreServer {
+			return &LazyStoreServer{GenServer: genserv, data: make(map[string]int)}
+		}, WithDeliveryJournal(journal))
+		err := crashed.CastReliable("put", KeyValuePair{"one", 1})
+		crashed.Close() // terminates before the cast is handled
+
+		// act
+		restarted := NewLazyStoreServer(WithDeliveryJournal(journal))
+		defer restarted.Close()
+		var v int
+		err2 := restarted.CallTimeout("get", "one", &v, time.Second)
+		restarted.WaitIdle(time.Second)
+		pending, _ := journal.Pending()
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, err2)
+		assert.Equal(t, 1, v)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("should keep failed cast in journal", func(t *testing.T) {
+		// arrange
+		journal := NewMemoryJournal()
+		s := NewLazyStoreServer(WithDeliveryJournal(journal))
+		defer s.Close()
+
+		// act
+		err := s.CastReliable("unknown", nil)
+		s.WaitIdle(time.Second)
+		pending, _ := journal.Pending()
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, []PendingCast{{ID: 1, ServiceMethod: "unknown"}}, pending)
+	})
+
+	t.Run("should acknowledge cast answered from memo", func(t *testing.T) {
+		// arrange
+		journal := NewMemoryJournal()
+		key := func(serviceMethod string, body any) (string, bool) {
+			return serviceMethod, serviceMethod == "square"
+		}
+		s := Listen(func(genserv GenServer) *SquareServer {
+			return &SquareServer{GenServer: genserv}
+		}, WithDeliveryJournal(journal), WithMemoize(key, time.Minute))
+		defer s.Close()
+		s.Call("square", 3, nil)
+
+		// act
+		err := s.CastReliable("square", 3)
+		s.WaitIdle(time.Second)
+		pending, _ := journal.Pending()
+
+		// assert
+		assert.Nil(t, err)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("should drop rejected cast from journal", func(t *testing.T) {
+		// arrange
+		journal := NewMemoryJournal()
+		errRejected := errors.New("rejected")
+		s := NewLazyStoreServer(WithDeliveryJournal(journal), WithRequestValidator(func(string, any) error {
+			return errRejected
+		}))
+		defer s.Close()
+
+		// act
+		err := s.CastReliable("put", KeyValuePair{"one", 1})
+		pending, _ := journal.Pending()
+
+		// assert
+		assert.ErrorIs(t, err, errRejected)
+		assert.Empty(t, pending)
+	})
+
+	t.Run("should return error if journal is not set", func(t *testing.T) {
+		// arrange
+		s := NewLazyStoreServer()
+		defer s.Close()
+
+		// act
+		err := s.CastReliable("put", KeyValuePair{"one", 1})
+
+		// assert
+		assert.ErrorIs(t, err, ErrNoDeliveryJournal)
+	})
+}
+
+var _ DeliveryJournal = (*MemoryJournal)(nil)
+
+// Keeps pending casts in memory, it survives server processes but not the test process
+type MemoryJournal struct {
+	mu      sync.Mutex
+	nextID  uint64
+	pending []PendingCast
+}
+
+func NewMemoryJournal() *MemoryJournal {
+	return &MemoryJournal{nextID: 1}
+}
+
+func (j *MemoryJournal) Append(serviceMethod string, args any) (uint64, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	id := j.nextID
+	j.nextID++
+	j.pending = append(j.pending, PendingCast{ID: id, ServiceMethod: serviceMethod, Args: args})
+	return id, nil
+}
+
+func (j *MemoryJournal) Ack(id uint64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i, p := range j.pending {
+		if p.ID == id {
+			j.pending = append(j.pending[:i], j.pending[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (j *MemoryJournal) Pending() ([]PendingCast, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]PendingCast(nil), j.pending...), nil
+}
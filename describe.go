@@ -0,0 +1,55 @@
+package genserver
+
+import (
+	"errors"
+	"reflect"
+)
+
+var ErrNotDescribable = errors.New("behaviour does not describe its methods")
+
+// Describes a service method supported by a behaviour. A nil type means the method takes no argument
+// or has no reply
+type MethodInfo struct {
+	Name  string
+	Arg   reflect.Type
+	Reply reflect.Type
+}
+
+// Reports whether `arg` can be sent to the method, so a client can validate it before calling
+func (m MethodInfo) Accepts(arg any) bool {
+	if arg == nil {
+		if m.Arg == nil {
+			return true
+		}
+		switch m.Arg.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func:
+			return true
+		}
+		return false
+	}
+	return m.Arg != nil && reflect.TypeOf(arg).AssignableTo(m.Arg)
+}
+
+// Optional contract of a behaviour that lists its service methods, e.g. for a generic CLI over any server process
+type Describable interface {
+	Behaviour
+	DescribeMethods() []MethodInfo
+}
+
+// Returns the methods of the behaviour of `s`. `DescribeMethods` runs on the server process, serialized with requests
+func DescribeMethods(s GenServer) ([]MethodInfo, error) {
+	var methods []MethodInfo
+	var err error
+	snapshotErr := s.Snapshot(func(b Behaviour) {
+		d, ok := b.(Describable)
+		if !ok {
+			err = ErrNotDescribable
+			return
+		}
+		methods = d.DescribeMethods()
+	})
+	if err := errors.Join(snapshotErr, err); err != nil {
+		return nil, err
+	}
+	return methods, nil
+}
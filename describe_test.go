@@ -0,0 +1,34 @@
+package genserver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeMethods(t *testing.T) {
+	t.Run("should return error if behaviour does not describe its methods", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+
+		// act
+		methods, err := DescribeMethods(s)
+
+		// assert
+		assert.ErrorIs(t, err, ErrNotDescribable)
+		assert.Nil(t, methods)
+	})
+
+	t.Run("should accept only arguments assignable to argument type", func(t *testing.T) {
+		// arrange
+		info := MethodInfo{Name: "put", Arg: reflect.TypeOf(KeyValuePair{})}
+
+		// act + assert
+		assert.True(t, info.Accepts(KeyValuePair{"one", 1}))
+		assert.False(t, info.Accepts("one"))
+		assert.False(t, info.Accepts(nil))
+		assert.True(t, MethodInfo{Name: "value"}.Accepts(nil))
+	})
+}
@@ -0,0 +1,58 @@
+package genserver
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// Returned by `Dispatcher` when no handler is registered for a service method
+var ErrUnknownMethod = errors.New("unknown method")
+
+type HandlerFunc func(body any) (any, error)
+
+// Dispatcher is a `Behaviour` that routes requests to handlers registered by service method.
+// Embed it alongside `GenServer` instead of writing a `switch` inside `Handle`.
+//
+// `Register` is not synchronized. It's safe to call it before the server starts listening
+// or from within a handler, since handlers run on the server process
+type Dispatcher struct {
+	handlers map[string]HandlerFunc
+}
+
+var _ Behaviour = (*Dispatcher)(nil)
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{handlers: make(map[string]HandlerFunc)}
+}
+
+func (d *Dispatcher) Register(serviceMethod string, fn HandlerFunc) {
+	d.handlers[serviceMethod] = fn
+}
+
+func (d *Dispatcher) Handle(serviceMethod string, _ uint64, body any) (any, error) {
+	fn, ok := d.handlers[serviceMethod]
+	if !ok {
+		return nil, ErrUnknownMethod
+	}
+	return fn(body)
+}
+
+var handlerFuncType = reflect.TypeOf((func(body any) (any, error))(nil))
+
+// Builds a `Dispatcher` from the exported methods of `receiver` of the `HandlerFunc` signature,
+// keyed by the lowercased method name, e.g. `Get` handles "get". Other methods are ignored.
+// Reflection is done once, dispatching is a map lookup
+func Methods(receiver any) *Dispatcher {
+	d := NewDispatcher()
+	v := reflect.ValueOf(receiver)
+	t := v.Type()
+	for i := 0; i < t.NumMethod(); i++ {
+		method := v.Method(i)
+		if method.Type() != handlerFuncType {
+			continue
+		}
+		d.Register(strings.ToLower(t.Method(i).Name), method.Interface().(func(body any) (any, error)))
+	}
+	return d
+}
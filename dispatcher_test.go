@@ -0,0 +1,132 @@
+package genserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher(t *testing.T) {
+	t.Run("should dispatch request to registered handler", func(t *testing.T) {
+		// arrange
+		s := NewPingServer()
+		defer s.Close()
+
+		// act
+		var reply string
+		err := s.Call("echo", "foo", &reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "foo", reply)
+	})
+
+	t.Run("should return unknown method error", func(t *testing.T) {
+		// arrange
+		s := NewPingServer()
+		defer s.Close()
+
+		// act
+		err := s.Call("ping", nil, nil)
+
+		// assert
+		assert.ErrorIs(t, err, ErrUnknownMethod)
+	})
+
+	t.Run("should call handler registered at runtime", func(t *testing.T) {
+		// arrange
+		s := NewPingServer()
+		defer s.Close()
+
+		// act
+		err := s.Call("enable_ping", nil, nil)
+		var reply string
+		err2 := s.Call("ping", nil, &reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, err2)
+		assert.Equal(t, "pong", reply)
+	})
+}
+
+func TestMethods(t *testing.T) {
+	newServer := func() *MethodsServer {
+		return Listen(func(genserv GenServer) *MethodsServer {
+			return &MethodsServer{GenServer: genserv, Dispatcher: Methods(&Counters{data: make(map[string]int)})}
+		})
+	}
+
+	t.Run("should dispatch request to method by its lowercased name", func(t *testing.T) {
+		// arrange
+		s := newServer()
+		defer s.Close()
+
+		// act
+		err1 := s.Call("put", KeyValuePair{"one", 1}, nil)
+		var reply int
+		err2 := s.Call("get", "one", &reply)
+
+		// assert
+		assert.Nil(t, err1)
+		assert.Nil(t, err2)
+		assert.Equal(t, 1, reply)
+	})
+
+	t.Run("should ignore methods of other signatures", func(t *testing.T) {
+		// arrange
+		s := newServer()
+		defer s.Close()
+
+		// act
+		err := s.Call("len", nil, nil)
+
+		// assert
+		assert.ErrorIs(t, err, ErrUnknownMethod)
+	})
+}
+
+func NewPingServer(opts ...Option) *PingServer {
+	return Listen(func(genserv GenServer) *PingServer {
+		s := &PingServer{GenServer: genserv, Dispatcher: NewDispatcher()}
+		s.Register("echo", func(body any) (any, error) {
+			return body, nil
+		})
+		s.Register("enable_ping", func(_ any) (any, error) {
+			// runs on the server process, so registration is synchronized
+			s.Register("ping", func(_ any) (any, error) {
+				return "pong", nil
+			})
+			return nil, nil
+		})
+		return s
+	}, opts...)
+}
+
+type PingServer struct {
+	GenServer
+	*Dispatcher
+}
+
+type MethodsServer struct {
+	GenServer
+	*Dispatcher
+}
+
+type Counters struct {
+	data map[string]int
+}
+
+func (c *Counters) Get(body any) (any, error) {
+	return c.data[body.(string)], nil
+}
+
+func (c *Counters) Put(body any) (any, error) {
+	kvp := body.(KeyValuePair)
+	c.data[kvp.Key] = kvp.Value
+	return nil, nil
+}
+
+func (c *Counters) Len() int {
+	return len(c.data)
+}
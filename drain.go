@@ -0,0 +1,39 @@
+package genserver
+
+import "net/rpc"
+
+// Hands the requests waiting in the mailbox over to `dst` and closes the server process.
+// Intake is stopped first, see `GenServer.Quiesce`. The request being handled completes as usual, the others
+// are sent to `dst` in order and their callers get the replies of `dst`. The server process must be running
+func (s *genServer) DrainTo(dst GenServer) error {
+	s.Quiesce()
+	s.codec.drainTo.Store(&dst)
+	// handled after every request that was in the mailbox has been sent to `dst`
+	if err := s.Snapshot(func(Behaviour) {}); err != nil {
+		return err
+	}
+	s.codec.forwarding.Wait()
+	return s.Close()
+}
+
+// Sends the request to `dst` and routes its reply to the original caller
+func (c *genServerCodec) forward(dst GenServer, req request) {
+	c.forwarding.Add(1)
+	var reply any
+	// the request is cast to keep its place in order, but its caller may still wait
+	call := castMode(dst, req.serviceMethod, req.body, &reply, make(chan *rpc.Call, 1), req.meta, req.mode)
+	go func() {
+		defer c.forwarding.Done()
+		<-call.Done
+		c.respond(req, reply, call.Error)
+	}()
+}
+
+// Reports whether the body is a request of the server process itself rather than of the behaviour
+func internal(body any) bool {
+	switch body.(type) {
+	case snapshot, tick, ping:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package genserver
+
+import (
+	"net/rpc"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainTo(t *testing.T) {
+	t.Run("should hand queued requests over to another server", func(t *testing.T) {
+		// arrange
+		src := Listen(func(genserv GenServer) *ReplicaServer {
+			return &ReplicaServer{GenServer: genserv, name: "src", delay: 100 * time.Millisecond}
+		})
+		dst := NewEchoServer(0)
+		defer dst.Close()
+
+		var handled string
+		first := src.Cast("get", nil, &handled, nil)
+		time.Sleep(20 * time.Millisecond) // the first request is being handled
+		replies := make([]int, 3)
+		calls := make([]*rpc.Call, 3)
+		for i := range calls {
+			calls[i] = src.Cast("echo", i+1, &replies[i], nil)
+		}
+
+		// act
+		err := src.DrainTo(dst)
+
+		// assert
+		assert.Nil(t, err)
+		<-first.Done
+		assert.Nil(t, first.Error)
+		assert.Equal(t, "src", handled)
+		for _, call := range calls {
+			<-call.Done
+			assert.Nil(t, call.Error)
+		}
+		assert.Equal(t, []int{1, 2, 3}, replies)
+		<-src.Done()
+	})
+
+	t.Run("should reject new requests while draining", func(t *testing.T) {
+		// arrange
+		src := NewEchoServer(0)
+		dst := NewEchoServer(0)
+		defer dst.Close()
+
+		// act
+		err := src.DrainTo(dst)
+		call := src.Cast("echo", "foo", nil, nil)
+		<-call.Done
+
+		// assert
+		assert.Nil(t, err)
+		assert.Error(t, call.Error)
+	})
+}
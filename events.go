@@ -0,0 +1,112 @@
+package genserver
+
+import (
+	"fmt"
+	"sync"
+)
+
+type EventKind int
+
+const (
+	// The server process is ready to handle requests
+	EventStarted EventKind = iota
+	// The behaviour has handled the request, its response is about to be sent. `Seq` and `ServiceMethod` are set
+	EventHandledRequest
+	// `Close` has been called
+	EventClosing
+	// The listen loop has exited. `Reason` is nil if the server process was closed normally.
+	// It's the last event, the channel is closed after it
+	EventTerminated
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventStarted:
+		return "Started"
+	case EventHandledRequest:
+		return "HandledRequest"
+	case EventClosing:
+		return "Closing"
+	case EventTerminated:
+		return "Terminated"
+	}
+	return fmt.Sprintf("EventKind(%d)", int(k))
+}
+
+// Lifecycle transition of a server process, see `GenServer.Events`
+type Event struct {
+	Kind          EventKind
+	Seq           uint64
+	ServiceMethod string
+	Reason        error
+}
+
+// Defines what happens when the events channel is full
+type EventOverflow int
+
+const (
+	// New events are dropped, the server process is never slowed down by a slow subscriber
+	DropEvents EventOverflow = iota
+	// The server process waits for the subscriber
+	BlockOnEvents
+)
+
+type eventStream struct {
+	mu       sync.Mutex
+	ch       chan Event
+	overflow EventOverflow
+	closed   bool
+}
+
+// Returns nil if events are disabled
+func newEventStream(capacity int, overflow EventOverflow) *eventStream {
+	if capacity < 0 {
+		return nil
+	}
+	return &eventStream{ch: make(chan Event, capacity), overflow: overflow}
+}
+
+func (s *eventStream) channel() <-chan Event {
+	if s == nil {
+		return nil
+	}
+	return s.ch
+}
+
+func (s *eventStream) emit(e Event) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.send(e)
+}
+
+// Emits the final event and closes the channel
+func (s *eventStream) terminate(reason error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.send(Event{Kind: EventTerminated, Reason: reason})
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// Must be called with `s.mu` held
+func (s *eventStream) send(e Event) {
+	if s.closed {
+		return
+	}
+	if s.overflow == BlockOnEvents {
+		s.ch <- e
+		return
+	}
+	select {
+	case s.ch <- e:
+	default:
+	}
+}
@@ -0,0 +1,72 @@
+package genserver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvents(t *testing.T) {
+	t.Run("should emit lifecycle transitions in order", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0, WithEvents(16, BlockOnEvents))
+
+		// act
+		err := s.Call("echo", "foo", nil)
+		s.Close()
+		var events []Event
+		for e := range s.Events() {
+			events = append(events, e)
+		}
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, []Event{
+			{Kind: EventStarted},
+			{Kind: EventHandledRequest, Seq: 0, ServiceMethod: "echo"},
+			{Kind: EventClosing},
+			{Kind: EventTerminated},
+		}, events)
+	})
+
+	t.Run("should report reason of termination", func(t *testing.T) {
+		// arrange
+		expectedErr := errors.New("something went wrong")
+		s := NewPanicServer(expectedErr, WithPanicPolicy(PanicRestart), WithEvents(16, BlockOnEvents))
+
+		// act
+		s.Call("", nil, nil)
+		var last Event
+		for e := range s.Events() {
+			last = e
+		}
+
+		// assert
+		assert.Equal(t, EventTerminated, last.Kind)
+		assert.ErrorIs(t, last.Reason, expectedErr)
+	})
+
+	t.Run("should drop events when nobody reads them", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0, WithEvents(1, DropEvents))
+		defer s.Close()
+
+		// act
+		for i := 0; i < 10; i++ {
+			s.Call("echo", i, nil)
+		}
+
+		// assert
+		assert.Equal(t, Event{Kind: EventStarted}, <-s.Events())
+	})
+
+	t.Run("should return nil channel if events are disabled", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+
+		// act + assert
+		assert.Nil(t, s.Events())
+	})
+}
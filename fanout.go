@@ -0,0 +1,38 @@
+package genserver
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Returned by `FanOutFirst` when there is no server to call
+var ErrNoServers = errors.New("no servers")
+
+// Sends the request to all the servers and returns the first successful reply, the other calls are abandoned.
+// Returns the errors of all the servers joined if none has succeeded within `timeout`
+func FanOutFirst(servers []GenServer, serviceMethod string, args any, timeout time.Duration) (any, error) {
+	if len(servers) == 0 {
+		return nil, ErrNoServers
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	results := make(chan result[any], len(servers))
+	for _, s := range servers {
+		go func(s GenServer) {
+			var reply any
+			err := s.CallContext(ctx, serviceMethod, args, &reply)
+			results <- result[any]{Value: reply, Error: err}
+		}(s)
+	}
+	errs := make([]error, 0, len(servers))
+	for range servers {
+		r := <-results
+		if r.Error == nil {
+			return r.Value, nil
+		}
+		errs = append(errs, r.Error)
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,76 @@
+package genserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFanOutFirst(t *testing.T) {
+	newReplica := func(name string, delay time.Duration) GenServer {
+		return Listen(func(genserv GenServer) *ReplicaServer {
+			return &ReplicaServer{GenServer: genserv, name: name, delay: delay}
+		})
+	}
+
+	t.Run("should return reply of the fastest replica", func(t *testing.T) {
+		// arrange
+		replicas := []GenServer{
+			newReplica("slow", time.Second),
+			newReplica("fast", 0),
+			newReplica("slower", 2*time.Second),
+		}
+		for _, r := range replicas {
+			defer r.Close()
+		}
+
+		// act
+		start := time.Now()
+		reply, err := FanOutFirst(replicas, "get", nil, time.Minute)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "fast", reply)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("should return errors of all replicas if none succeeded", func(t *testing.T) {
+		// arrange
+		expectedErr := errors.New("something went wrong")
+		failing := NewPanicServer(expectedErr)
+		defer failing.Close()
+		slow := newReplica("slow", time.Second)
+		defer slow.Close()
+
+		// act
+		reply, err := FanOutFirst([]GenServer{failing, slow}, "get", nil, 50*time.Millisecond)
+
+		// assert
+		assert.Nil(t, reply)
+		assert.ErrorIs(t, err, expectedErr)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("should return error if there are no servers", func(t *testing.T) {
+		// act
+		_, err := FanOutFirst(nil, "get", nil, time.Second)
+
+		// assert
+		assert.ErrorIs(t, err, ErrNoServers)
+	})
+}
+
+// Replies with its name after a delay
+type ReplicaServer struct {
+	GenServer
+	name  string
+	delay time.Duration
+}
+
+func (s *ReplicaServer) Handle(_ string, _ uint64, _ any) (any, error) {
+	time.Sleep(s.delay)
+	return s.name, nil
+}
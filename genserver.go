@@ -1,125 +1,998 @@
 package genserver
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/rpc"
 	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 func Reply[T any](call *rpc.Call) T {
 	return *(call.Reply.(*T))
 }
 
+// Sends a request whose handler both mutates the state and returns the new one, saving a separate
+// round trip to read the state
+func CallReturningState[T any](s GenServer, serviceMethod string, args any) (T, error) {
+	var state T
+	err := s.Call(serviceMethod, args, &state)
+	return state, err
+}
+
+// Sends a request and maps its reply of type `T` with `f`. `f` is not called if the request fails
+func CallMap[T, R any](s GenServer, serviceMethod string, args any, f func(T) R) (R, error) {
+	var reply T
+	if err := s.Call(serviceMethod, args, &reply); err != nil {
+		var zero R
+		return zero, err
+	}
+	return f(reply), nil
+}
+
 type Behaviour interface {
 	Handle(serviceMethod string, seq uint64, body any) (any, error)
 }
 
+// Optional contract. `Init` is called by the server process before it handles any request.
+// If `Init` fails the server process is closed
+type InitBehaviour interface {
+	Behaviour
+	Init() error
+}
+
+// Optional contract. If implemented, `HandleContext` is called instead of `Handle` with the context
+// passed to `GenServer.CallContext`, or `context.Background()` for other calls. Pass it to nested calls
+// to other server processes, so they are abandoned together with the original one.
+// It's not called if the behaviour is a `CancelableBehaviour` too
+type ContextBehaviour interface {
+	Behaviour
+	HandleContext(ctx context.Context, serviceMethod string, seq uint64, body any) (any, error)
+}
+
+// Optional contract. If implemented, `HandleTimed` is called instead of `Handle` with the time
+// the request was sent, so the handler can tell time spent in the mailbox from its own execution time.
+// It's not called if the behaviour is a `CancelableBehaviour` or a `ContextBehaviour` too
+type TimedBehaviour interface {
+	Behaviour
+	HandleTimed(serviceMethod string, seq uint64, body any, enqueuedAt time.Time) (any, error)
+}
+
+// Optional contract. If implemented, `HandleCancelable` is called instead of `Handle` with a channel
+// that's closed when the caller stops waiting for the reply, see `GenServer.CallContext`.
+// The channel is never closed for requests sent without a context. It takes precedence over the other
+// `Handle` variants: `HandleContext`, `HandleTimed` and `HandleMode` aren't called if the behaviour implements them too
+type CancelableBehaviour interface {
+	Behaviour
+	HandleCancelable(serviceMethod string, seq uint64, body any, cancel <-chan struct{}) (any, error)
+}
+
+// Optional contract. If implemented, `HandleMode` is called instead of `Handle` with the way the request
+// was sent, so a method can insist on a confirmed reply, see `ErrMustUseCall`. It comes last: it's only called
+// if the behaviour is none of `CancelableBehaviour`, `ContextBehaviour` and `TimedBehaviour`
+type ModeBehaviour interface {
+	Behaviour
+	HandleMode(mode Mode, serviceMethod string, seq uint64, body any) (any, error)
+}
+
+// How a request was sent
+type Mode int
+
+const (
+	// The caller waits for the reply: `Call` and its variants
+	ModeCall Mode = iota
+	// Fire-and-forget: `Cast` and its variants, including `CastAck`, `CastReliable` and `SendAfter`
+	ModeCast
+)
+
+func (m Mode) String() string {
+	if m == ModeCast {
+		return "cast"
+	}
+	return "call"
+}
+
+// Optional contract. If implemented, requests of methods missing from `WithKnownMethods`
+// are routed to `HandleUnknown` instead of `Handle`
+type FallbackBehaviour interface {
+	Behaviour
+	HandleUnknown(serviceMethod string, seq uint64, body any) (any, error)
+}
+
+// Optional contract. If implemented, `CanShutdown` is consulted on the server process before `GenServer.Close`
+// closes it. A non-nil error aborts the shutdown, e.g. while there is unflushed state, and is returned by `Close`.
+// It's not consulted when the server process terminates because of a failure
+type PrepareShutdownBehaviour interface {
+	Behaviour
+	CanShutdown() error
+}
+
+// Returned by `Behaviour.Handle` to defer a request it can't handle yet (selective receive).
+// Postponed requests are handled again, in arrival order, after the next request that is not postponed
+var Postpone = errors.New("postpone request")
+
+// Returned by the interceptor set by `WithRequestInterceptor` to drop a request: it's not handled and its caller
+// gets no reply until the server process terminates, e.g. to exercise timeouts of the client
+var Drop = errors.New("drop request")
+
+// Returned to the caller when a request was postponed more than `WithPostponeLimit` times
+var ErrPostponeLimit = errors.New("request postponed too many times")
+
+var (
+	ErrAlreadyStarted = errors.New("server process already started")
+	// Returned, or raised by `Listen` and `Build`, when the behaviour is nil, including a nil pointer
+	ErrNilBehaviour = errors.New("behaviour is nil")
+	// Reason of termination of a server process that has handled the number of requests set by `WithMaxRequests`
+	ErrMaxRequests = errors.New("server process reached its request limit")
+	// Returned by a `ModeBehaviour` for a method that needs a confirmed reply but was cast
+	ErrMustUseCall = errors.New("method must be called, not cast")
+	// Returned by a `ModeBehaviour` for a method that must not hold its caller but was called
+	ErrMustUseCast = errors.New("method must be cast, not called")
+	// Returned by `InitReject` policy for requests sent before `Init` has completed
+	ErrNotReady = errors.New("server process is not ready")
+	// Returned for requests sent while the server process is closing, including those blocked on a full mailbox
+	ErrServerClosing = errors.New("server process is closing")
+	// Returned for requests that have waited in the mailbox longer than `WithMessageTTL`
+	ErrRequestExpired = errors.New("request expired in the mailbox")
+	// Returned by `GenServer.CastAck` when there is no free slot in the mailbox
+	ErrMailboxFull = errors.New("mailbox is full")
+	// Returned by blocking calls when `WithBulkhead` limit of concurrent calls is reached
+	ErrBulkheadFull = errors.New("too many concurrent calls")
+	// Returned by `GenServer.CallTimeout` when the reply hasn't arrived in time
+	ErrCallTimeout = errors.New("call timed out")
+	// Returned when a handler makes a blocking call to its own server process, which would deadlock.
+	// Calls aren't checked if `WithoutReentrancyCheck` is set, `Close`, `Pause` and `WaitIdle` always are
+	ErrReentrantCall = errors.New("reentrant call to the server process from its own handler")
+	// Returned when the handler of a method runs longer than its `WithMethodTimeout` budget
+	ErrMethodTimeout = errors.New("method timed out")
+	// Returned by `GenServer.WaitIdle` when the server process is still busy after the timeout
+	ErrNotIdle = errors.New("server process is not idle")
+)
+
+// With the default mailbox, requests sent from a single goroutine are handled in the order they were sent,
+// whether they are sent by `Cast` or `Call`: both put the request into the mailbox before returning.
+// Other mailboxes keep the order only partially: `WithPriorityQueue` and `WithMethodPriority` among requests
+// of equal priority, `WithFairQueuing` among requests of the same key, and with `WithOverflow` a request spilled
+// into the overflow queue waits until the mailbox is empty. A request postponed by `Postpone` is handled after
+// later ones, and a `Redirect` hands it over to another server process
 type GenServer interface {
 	Listen(Behaviour)
+	// Same as `Listen` but shuts the server process down gracefully once `ctx` is cancelled: intake is stopped,
+	// the mailbox is drained, unpausing the server process if paused, and then it's closed, even if `CanShutdown`
+	// refuses. Returns `ctx.Err()` in that case and nil if the server process terminated on its own,
+	// e.g. within an `errgroup.Group`
+	ListenContext(ctx context.Context, behaviour Behaviour) error
 	Cast(serviceMethod string, args any, reply any, done chan *rpc.Call) *rpc.Call
+	// Returns once the request is in the mailbox, without waiting for it to be handled.
+	// Fails with `ErrMailboxFull` instead of waiting for a free slot. The reply of the behaviour is dropped
+	CastAck(serviceMethod string, args any) error
+	// Persists the request in the journal set by `WithDeliveryJournal` before putting it into the mailbox,
+	// and acknowledges it once the behaviour has handled it successfully. Unacknowledged requests are sent again
+	// when a server process with the same journal starts, so they are handled at least once. The reply is dropped
+	CastReliable(serviceMethod string, args any) error
+	// Same as `Cast` but `cb` is invoked exactly once, from another goroutine, when the call completes
+	CastCallback(serviceMethod string, args any, reply any, cb func(*rpc.Call))
+	// Casts the request after `d`, e.g. from `Handle` to expire something the behaviour holds. It's handled
+	// in turn with other requests. `cancel` reports whether the request was cancelled before it was sent.
+	// Nothing is sent once the server process has terminated, and the reply is dropped
+	SendAfter(d time.Duration, serviceMethod string, args any) (cancel func() bool)
 	Call(serviceMethod string, args any, reply any) error
+	// Same as `Call`, spelled out for callers that reuse one `reply` buffer across calls.
+	// The reply value is assigned in place, no intermediate copy is allocated.
+	// `reply` must not be read or written concurrently while a call into it is in flight
+	CallInto(serviceMethod string, args any, reply any) error
+	// Same as `Call` but also returns the completed `rpc.Call`, the same one `Cast` would have returned
+	CallFull(serviceMethod string, args any, reply any) (*rpc.Call, error)
+	// Same as `Cast` but the request carries caller-supplied attributes
+	CastWithMeta(serviceMethod string, args any, reply any, done chan *rpc.Call, meta Meta) *rpc.Call
+	// Same as `Call` but the request carries caller-supplied attributes
+	CallWithMeta(serviceMethod string, args any, reply any, meta Meta) error
+	// Same as `Call` but stops waiting when `ctx` is done. The request is not withdrawn from the mailbox,
+	// its late response is routed to the `WithOrphanHandler` handler and `reply` is left untouched
+	CallContext(ctx context.Context, serviceMethod string, args any, reply any) error
+	// Same as `CallContext` but stops waiting after `timeout` measured by the `Clock` of the server process.
+	// Fails with `ErrCallTimeout`
+	CallTimeout(serviceMethod string, args any, reply any, timeout time.Duration) error
+	// Same as `Call` but also returns the round-trip time from sending the request to receiving the reply,
+	// measured by the `Clock` of the server process
+	CallTimed(serviceMethod string, args any, reply any) (time.Duration, error)
+	// Fails with the error of `PrepareShutdownBehaviour.CanShutdown` if the behaviour vetoes the shutdown,
+	// the server process keeps running then
 	Close() error
+	// Returns a channel that's closed when the server process terminates
+	Done() <-chan struct{}
+	// Launches the listen loop of a server process created by `Build`
+	Start() error
+	// Returns the live behaviour of the server process
+	Behaviour() Behaviour
+	// Runs `f` on the server process, serialized with requests, and waits for it.
+	// Use it to read the state of the behaviour without data races
+	Snapshot(f func(Behaviour)) error
+	// Returns the stream of lifecycle transitions enabled by `WithEvents`, nil otherwise
+	Events() <-chan Event
+	// Returns the last handled requests recorded by `WithJournal`, oldest first. Nil if the journal is disabled.
+	// Stays readable after the server process has terminated
+	RecentRequests() []RequestLog
+	// Returns the current counters of the server process. Safe to call from any goroutine
+	Stats() ServerStats
+	// Checks that the server process handles requests within `timeout`, and asks the behaviour about its health
+	// if it's a `HealthBehaviour`. Works for any behaviour, the reserved `PingMethod` never reaches `Handle`
+	Ping(timeout time.Duration) (Health, error)
+	// Returns the time since the server process started handling requests, after `Init` has completed.
+	// Zero if it hasn't started
+	Uptime() time.Duration
+	// Returns the state published by the last successful request, see `WithCopyOnWriteState`. It doesn't go
+	// through the mailbox, so it's lock-free and can be called from any goroutine. Nil if the option is not set
+	LoadState() any
+	// Returns the number of calls abandoned by their callers, e.g. timed out, whose responses haven't been
+	// drained yet. The responses are still consumed and dropped, so it returns to zero once the mailbox is handled
+	AbandonedCalls() int
+	// Stops accepting requests: new ones fail with `ErrServerClosing` while those already in the mailbox
+	// are still handled. The server process stays alive, e.g. for `Snapshot`, until it's closed
+	Quiesce()
+	// Stops handling requests without closing the server process: once it returns no handler runs until `Unpause`,
+	// so the state of the behaviour can be read or maintained directly. Requests keep accumulating in the mailbox,
+	// unless intake is stopped by `Quiesce` too. Called from a handler, it takes effect after the handler returns
+	Pause()
+	// Accepts requests again after `Quiesce`. A paused server process stays paused
+	Resume()
+	// Handles requests again after `Pause`. Intake stopped by `Quiesce` stays stopped
+	Unpause()
+	// Hands the requests waiting in the mailbox over to `dst` and closes the server process
+	DrainTo(dst GenServer) error
+	// Returns the number of requests being handled by the behaviour right now, as opposed to those
+	// waiting in the mailbox
+	InFlight() int
+	// Blocks until the mailbox is empty and no request is being handled, e.g. to assert the state after a burst
+	// of casts. Fails with `ErrNotIdle` after `timeout`. Postponed requests don't keep the server process busy
+	WaitIdle(timeout time.Duration) error
+	// Returns the context carrying server-scoped values set by `WithServerValue`, e.g. a logger for the behaviour
+	Context() context.Context
+	// Delivers `event` to the subscribers of `topic` without blocking, e.g. from a handler to announce a change.
+	// A subscriber that doesn't keep up loses its oldest events
+	Publish(topic string, event any)
+	// Returns a channel of the events published to `topic`. It's closed when the server process terminates
+	Subscribe(topic string) <-chan any
+	// Sends the request and returns the progress reported by the behaviour while it handles it, and a function
+	// that waits for the final reply. The channel is closed before the reply is available, so it can be ranged over.
+	// A caller that doesn't keep up loses the oldest reports
+	CallWithProgress(serviceMethod string, args any) (<-chan Progress, func() (any, error))
+	// Reports the progress of the request being handled to its caller, if it was sent by `CallWithProgress`.
+	// Must be called from the handler, it never blocks
+	ReportProgress(progress Progress)
 }
 
-func Listen[T Behaviour](f func(GenServer) T) T {
-	serv := NewGenServer()
-	behaviour := f(serv)
-	go serv.Listen(behaviour)
+// Panics with `ErrNilBehaviour` if `f` returns a nil behaviour
+func Listen[T Behaviour](f func(GenServer) T, opts ...Option) T {
+	serv, behaviour, err := build(f, opts)
+	if err != nil {
+		panic(err)
+	}
+	serv.Start()
 	return behaviour
 }
 
-func NewGenServer() *genServer {
-	return newGenServer(4096, 4096)
+// Same as `Listen` but waits for `InitBehaviour.Init` and returns its error, or `ErrNilBehaviour`
+func ListenE[T Behaviour](f func(GenServer) T, opts ...Option) (T, error) {
+	serv, behaviour, err := build(f, opts)
+	if err != nil {
+		return behaviour, err
+	}
+	serv.Start()
+	<-serv.ready
+	return behaviour, serv.initErr
+}
+
+// Same as `Listen` but the server process doesn't handle requests until `GenServer.Start` is called.
+// Requests sent before that are kept in the mailbox
+func Build[T Behaviour](f func(GenServer) T, opts ...Option) T {
+	_, behaviour, err := build(f, opts)
+	if err != nil {
+		panic(err)
+	}
+	return behaviour
 }
 
-func newGenServer(incap uint, outcap uint) *genServer {
-	requests := make(chan request, incap)
+// Closes the servers in reverse order, as deferred `Close` calls would, and joins the errors.
+// Already closed servers are skipped
+func CloseAll(servers ...GenServer) error {
+	var errs []error
+	for i := len(servers) - 1; i >= 0; i-- {
+		if err := servers[i].Close(); err != nil && !errors.Is(err, rpc.ErrShutdown) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func build[T Behaviour](f func(GenServer) T, opts []Option) (*genServer, T, error) {
+	serv := NewGenServer(opts...)
+	behaviour := f(serv)
+	if isNil(behaviour) {
+		serv.close()
+		return nil, behaviour, ErrNilBehaviour
+	}
+	serv.setBehaviour(behaviour)
+	return serv, behaviour, nil
+}
+
+// Reports whether the behaviour is nil, or wraps a nil pointer that would fail on the first request
+func isNil(behaviour Behaviour) bool {
+	if behaviour == nil {
+		return true
+	}
+	v := reflect.ValueOf(behaviour)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+func NewGenServer(opts ...Option) *genServer {
+	return newGenServer(4096, 4096, opts...)
+}
+
+func newGenServer(incap uint, outcap uint, opts ...Option) *genServer {
+	options := newOptions(opts)
+	var requests mailbox = newChanMailbox(incap)
+	switch {
+	case options.priorityCapacity > 0:
+		requests = newPriorityMailbox(uint(options.priorityCapacity), options.methodPriority)
+	case options.methodPriority != nil:
+		requests = newPriorityMailbox(incap, options.methodPriority)
+	case options.fairKey != nil:
+		requests = newFairMailbox(incap, options.fairKey)
+	case options.overflowCapacity > 0:
+		requests = newOverflowMailbox(incap, uint(options.overflowCapacity))
+	}
 	responses := make(chan response, outcap)
-	codec := &genServerCodec{requests: requests, responses: responses}
+	codec := &genServerCodec{
+		requests:  requests,
+		responses: responses,
+		done:      make(chan struct{}),
+		events:    newEventStream(options.eventsCapacity, options.eventsOverflow),
+		journal:   newJournal(options.journalCapacity),
+		flights:   newFlights(options.singleFlightKey),
+		recorder:  newRecorder(options.recorder),
+		memo:      newMemo(options.memoizeKey, options.memoizeTTL, options.clock),
+		shedder:   newShedder(options.lowWater, options.highWater),
+		cow:       newCowState(options.cowInitial, options.cowApply),
+		gate:      newGate(),
+		options:   options,
+	}
 	client := rpc.NewClientWithCodec(codec)
-	return &genServer{codec: codec, client: client}
+	ctx := context.Background()
+	for _, v := range options.values {
+		ctx = context.WithValue(ctx, v.key, v.value)
+	}
+	serv := &genServer{codec: codec, client: client, ready: make(chan struct{}), topics: newTopics()}
+	serv.ctx = context.WithValue(ctx, serverKey{}, serv)
+	if options.bulkhead > 0 {
+		serv.bulkhead = make(chan struct{}, options.bulkhead)
+	}
+	return serv
 }
 
 type genServer struct {
-	codec  *genServerCodec
-	client *rpc.Client
+	codec     *genServerCodec
+	client    *rpc.Client
+	mu        sync.Mutex
+	behaviour Behaviour
+	started   atomic.Bool
+	closing   atomic.Bool
+	initOnce  sync.Once
+	initErr   error
+	ready     chan struct{} // closed when `Init` has completed
+	startedAt time.Time     // guarded by `mu`
+	bulkhead  chan struct{} // slots of concurrent blocking calls, nil if unlimited
+	ctx       context.Context
+	topics    *topics
 }
 
 var _ GenServer = (*genServer)(nil)
 
 func (s *genServer) Cast(serviceMethod string, args any, reply any, done chan *rpc.Call) *rpc.Call {
-	return s.client.Go(serviceMethod, args, reply, done)
+	return s.cast(serviceMethod, &envelope{args: args, cast: true}, reply, done)
+}
+
+func (s *genServer) CastWithMeta(serviceMethod string, args any, reply any, done chan *rpc.Call, meta Meta) *rpc.Call {
+	return s.cast(serviceMethod, &envelope{args: args, meta: meta, cast: true}, reply, done)
+}
+
+func (s *genServer) castMode(serviceMethod string, args any, reply any, done chan *rpc.Call, meta Meta, mode Mode) *rpc.Call {
+	return s.cast(serviceMethod, &envelope{args: args, meta: meta, cast: mode == ModeCast}, reply, done)
+}
+
+// Key of the server process in its own context, so it's found behind a behaviour embedding `GenServer`
+type serverKey struct{}
+
+// Returns the server process of this package behind `s`, if any, e.g. not a remote one
+func serverOf(s GenServer) (*genServer, bool) {
+	serv, ok := s.Context().Value(serverKey{}).(*genServer)
+	return serv, ok
+}
+
+// Implemented by the server process and by wrappers of it that must see every request they send
+type modeCaster interface {
+	castMode(serviceMethod string, args any, reply any, done chan *rpc.Call, meta Meta, mode Mode) *rpc.Call
+}
+
+// Casts the request to `dst` without blocking but with the given mode, e.g. `ModeCall` on behalf of a caller
+// that waits for the reply, so it isn't shed as a cast. A remote server process gets a plain cast
+func castMode(dst GenServer, serviceMethod string, args any, reply any, done chan *rpc.Call, meta Meta, mode Mode) *rpc.Call {
+	if s, ok := dst.(modeCaster); ok {
+		return s.castMode(serviceMethod, args, reply, done, meta, mode)
+	}
+	if s, ok := serverOf(dst); ok {
+		return s.castMode(serviceMethod, args, reply, done, meta, mode)
+	}
+	return dst.CastWithMeta(serviceMethod, args, reply, done, meta)
+}
+
+func (s *genServer) cast(serviceMethod string, env *envelope, reply any, done chan *rpc.Call) *rpc.Call {
+	if done == nil {
+		done = make(chan *rpc.Call, 10)
+	} else if cap(done) == 0 {
+		log.Panic("genserver: done channel is unbuffered")
+	}
+	// `rpc.Client` reports errors of the behaviour as `rpc.ServerError`.
+	// The caller gets its own `rpc.Call` that is completed with the original error
+	call := &rpc.Call{ServiceMethod: serviceMethod, Args: env.args, Reply: reply, Done: done}
+	inner := s.client.Go(serviceMethod, env, reply, donePool.Get().(chan *rpc.Call))
+	select {
+	case <-inner.Done: // completed synchronously, e.g. the server process is closed
+		donePool.Put(inner.Done)
+		complete(call, env, inner.Error)
+	default:
+		go func() {
+			<-inner.Done
+			donePool.Put(inner.Done)
+			complete(call, env, inner.Error)
+		}()
+	}
+	return call
+}
+
+func complete(call *rpc.Call, env *envelope, err error) {
+	call.Error = env.error(err)
+	if call.Reply == nil {
+		call.Reply = env.reply // captured by `WithCaptureNilReplies`
+	}
+	select {
+	case call.Done <- call:
+	default: // same as `rpc.Client`, discard if `done` has insufficient capacity
+	}
+}
+
+func (s *genServer) CastAck(serviceMethod string, args any) error {
+	env := &envelope{args: args, noWait: true, cast: true}
+	// `rpc.Client` writes the request on the caller's goroutine
+	call := s.client.Go(serviceMethod, env, nil, make(chan *rpc.Call, 1))
+	if env.enqueued {
+		return nil
+	}
+	<-call.Done
+	return env.error(call.Error)
+}
+
+func (s *genServer) CastCallback(serviceMethod string, args any, reply any, cb func(*rpc.Call)) {
+	call := s.Cast(serviceMethod, args, reply, make(chan *rpc.Call, 1))
+	go func() {
+		cb(<-call.Done)
+	}()
 }
 
 func (s *genServer) Call(serviceMethod string, args any, reply any) error {
-	return s.client.Call(serviceMethod, args, reply)
+	return s.call(serviceMethod, &envelope{args: args}, reply)
 }
 
+func (s *genServer) CallInto(serviceMethod string, args any, reply any) error {
+	return s.call(serviceMethod, &envelope{args: args}, reply)
+}
+
+func (s *genServer) CallFull(serviceMethod string, args any, reply any) (*rpc.Call, error) {
+	if s.codec.reentrantCall() {
+		return &rpc.Call{ServiceMethod: serviceMethod, Args: args, Reply: reply, Error: ErrReentrantCall}, ErrReentrantCall
+	}
+	if !s.acquire() {
+		return &rpc.Call{ServiceMethod: serviceMethod, Args: args, Reply: reply, Error: ErrBulkheadFull}, ErrBulkheadFull
+	}
+	defer s.release()
+	call := <-s.cast(serviceMethod, &envelope{args: args}, reply, make(chan *rpc.Call, 1)).Done
+	return call, call.Error
+}
+
+func (s *genServer) CallWithMeta(serviceMethod string, args any, reply any, meta Meta) error {
+	return s.call(serviceMethod, &envelope{args: args, meta: meta}, reply)
+}
+
+func (s *genServer) call(serviceMethod string, env *envelope, reply any) error {
+	if s.codec.reentrantCall() {
+		return ErrReentrantCall
+	}
+	if !s.acquire() {
+		return ErrBulkheadFull
+	}
+	defer s.release()
+	done := donePool.Get().(chan *rpc.Call)
+	call := <-s.client.Go(serviceMethod, env, reply, done).Done
+	donePool.Put(done)
+	return env.error(call.Error)
+}
+
+func (s *genServer) CallContext(ctx context.Context, serviceMethod string, args any, reply any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	env := &envelope{args: args, cancel: make(chan struct{}), ctx: ctx}
+	return callUntil(s, serviceMethod, env, reply, ctx.Done(), ctx.Err)
+}
+
+func (s *genServer) CallTimeout(serviceMethod string, args any, reply any, timeout time.Duration) error {
+	env := &envelope{args: args, cancel: make(chan struct{})}
+	fired, stop := timer(s.codec.options.clock, timeout)
+	defer stop()
+	return callUntil(s, serviceMethod, env, reply, fired, func() error {
+		return ErrCallTimeout
+	})
+}
+
+func (s *genServer) CallTimed(serviceMethod string, args any, reply any) (time.Duration, error) {
+	start := s.codec.options.clock.Now()
+	err := s.Call(serviceMethod, args, reply)
+	return s.codec.options.clock.Now().Sub(start), err
+}
+
+// Waits for the reply until `stop` fires. Then the call is abandoned and `stopErr` is returned
+func callUntil[T any](s *genServer, serviceMethod string, env *envelope, reply any, stop <-chan T, stopErr func() error) error {
+	if s.codec.reentrantCall() {
+		return ErrReentrantCall
+	}
+	if !s.acquire() {
+		return ErrBulkheadFull
+	}
+	defer s.release()
+	done := donePool.Get().(chan *rpc.Call)
+	call := s.client.Go(serviceMethod, env, reply, done)
+	select {
+	case <-call.Done:
+	case <-stop:
+		if env.abandon() {
+			s.codec.abandoned.Add(1)
+			return stopErr() // `done` is not recycled, `rpc.Client` may still send to it
+		}
+		<-call.Done // the response is being delivered
+	}
+	donePool.Put(done)
+	return env.error(call.Error)
+}
+
+// Takes a slot of the bulkhead without waiting. Returns false if all the slots are taken
+func (s *genServer) acquire() bool {
+	if s.bulkhead == nil {
+		return true
+	}
+	select {
+	case s.bulkhead <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *genServer) release() {
+	if s.bulkhead != nil {
+		<-s.bulkhead
+	}
+}
+
+// `request` and `response` travel through the mailbox by value, so they never escape to the heap.
+// The per-call allocation owned by the server is the completion channel passed to `rpc.Client`.
+// `rpc.Client` sends to it exactly once and the server drains it, so it can be safely reused
+var donePool = sync.Pool{
+	New: func() any {
+		return make(chan *rpc.Call, 1)
+	},
+}
+
+// Idempotent: only the first call closes the server process, subsequent calls return nil
 func (s *genServer) Close() error {
+	if err := s.prepareShutdown(); err != nil {
+		return err
+	}
+	return s.close()
+}
+
+// Asks the behaviour whether it may be closed, serialized with requests
+func (s *genServer) prepareShutdown() error {
+	b, ok := s.Behaviour().(PrepareShutdownBehaviour)
+	if !ok || s.closing.Load() {
+		return nil
+	}
+	if s.codec.reentrant() { // already on the server process
+		return b.CanShutdown()
+	}
+	if !s.started.Load() { // no listen loop would handle the snapshot, and no handler can run
+		return b.CanShutdown()
+	}
+	var err error
+	if s.codec.gate.hold(func() { err = b.CanShutdown() }) { // the snapshot would wait for `Unpause`
+		return err
+	}
+	if s.Snapshot(func(Behaviour) { err = b.CanShutdown() }) != nil {
+		return nil // the server process is gone or not ready, there is nothing to protect
+	}
+	return err
+}
+
+// Closes the server process without consulting the behaviour
+func (s *genServer) close() error {
+	if !s.closing.CompareAndSwap(false, true) {
+		return nil
+	}
+	s.codec.events.emit(Event{Kind: EventClosing})
+	s.codec.PreClose()
+	s.codec.gate.resume() // unpaused, the listen loop must drain the mailbox to terminate
 	return s.client.Close()
 }
 
+func (s *genServer) Done() <-chan struct{} {
+	return s.codec.done
+}
+
+func (s *genServer) Start() error {
+	behaviour := s.Behaviour()
+	if isNil(behaviour) {
+		return ErrNilBehaviour
+	}
+	if !s.started.CompareAndSwap(false, true) {
+		return ErrAlreadyStarted
+	}
+	go s.Listen(behaviour)
+	return nil
+}
+
+func (s *genServer) Behaviour() Behaviour {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.behaviour
+}
+
+func (s *genServer) setBehaviour(behaviour Behaviour) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.behaviour = behaviour
+}
+
+func (s *genServer) Snapshot(f func(Behaviour)) error {
+	return s.Call("", snapshot(f), nil)
+}
+
+func (s *genServer) Publish(topic string, event any) {
+	s.topics.publish(topic, event)
+}
+
+func (s *genServer) Subscribe(topic string) <-chan any {
+	return s.topics.subscribe(topic)
+}
+
+func (s *genServer) Events() <-chan Event {
+	return s.codec.events.channel()
+}
+
+func (s *genServer) RecentRequests() []RequestLog {
+	return s.codec.journal.recent()
+}
+
+func (s *genServer) Stats() ServerStats {
+	return ServerStats{
+		Handled:  s.codec.handled.Load(),
+		Errors:   s.codec.failed.Load(),
+		QueueLen: s.codec.requests.len(),
+		Uptime:   s.Uptime(),
+	}
+}
+
+func (s *genServer) AbandonedCalls() int {
+	// the response can be drained before the caller counts the call as abandoned
+	return int(max(s.codec.abandoned.Load(), 0))
+}
+
+func (s *genServer) Uptime() time.Duration {
+	s.mu.Lock()
+	startedAt := s.startedAt
+	s.mu.Unlock()
+	if startedAt.IsZero() {
+		return 0
+	}
+	return s.codec.options.clock.Now().Sub(startedAt)
+}
+
+func (s *genServer) InFlight() int {
+	return int(s.codec.inFlight.Load())
+}
+
+// Polls the number of outstanding requests, it's meant for tests and tooling rather than hot paths
+func (s *genServer) WaitIdle(timeout time.Duration) error {
+	if s.codec.reentrant() {
+		return ErrReentrantCall
+	}
+	clock := s.codec.options.clock
+	deadline := clock.Now().Add(timeout)
+	for s.codec.outstanding.Load() > 0 {
+		if clock.Now().After(deadline) {
+			return ErrNotIdle
+		}
+		time.Sleep(time.Millisecond) // on real time, a fake clock may never be advanced
+	}
+	return nil
+}
+
+func (s *genServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *genServer) Quiesce() {
+	s.codec.quiesced.Store(true)
+}
+
+func (s *genServer) Pause() {
+	s.codec.gate.pause(!s.codec.reentrant())
+}
+
+func (s *genServer) Resume() {
+	s.codec.quiesced.Store(false)
+}
+
+func (s *genServer) Unpause() {
+	s.codec.gate.resume()
+}
+
+// Panics with `ErrNilBehaviour` if the behaviour is nil, rather than on the first request
 func (s *genServer) Listen(behaviour Behaviour) {
-	s.codec.Listen(behaviour)
+	if isNil(behaviour) {
+		panic(ErrNilBehaviour)
+	}
+	behaviour, s.codec.replyWrapper = unwrapReply(behaviour)
+	s.started.Store(true) // when called directly rather than by `Start`
+	s.setBehaviour(behaviour)
+	if err := s.init(behaviour); err != nil {
+		s.close()
+		s.topics.close()
+		s.codec.events.terminate(err)
+		return
+	}
+	s.mu.Lock()
+	s.startedAt = s.codec.options.clock.Now()
+	s.mu.Unlock()
+	s.codec.events.emit(Event{Kind: EventStarted})
+	if _, ok := behaviour.(TickBehaviour); ok && s.codec.options.tickInterval > 0 {
+		go s.codec.tick()
+	}
+	if s.codec.options.deadlockThreshold > 0 {
+		go s.codec.detectDeadlocks()
+	}
+	go s.replayPending()
+	err := s.codec.Listen(behaviour)
+	if err != nil {
+		s.close()
+	}
+	s.topics.close()
+	s.codec.events.terminate(err)
+}
+
+func (s *genServer) ListenContext(ctx context.Context, behaviour Behaviour) error {
+	stop := context.AfterFunc(ctx, s.shutdown)
+	s.Listen(behaviour)
+	if !stop() {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Stops intake and closes the server process once the requests in the mailbox are handled
+func (s *genServer) shutdown() {
+	s.Quiesce()
+	s.codec.gate.resume()          // the mailbox is drained even if the server process is paused
+	s.Snapshot(func(Behaviour) {}) // handled after the requests already in the mailbox
+	if err := s.Close(); err != nil {
+		s.close()
+	}
+}
+
+func (s *genServer) init(behaviour Behaviour) error {
+	s.initOnce.Do(func() {
+		defer close(s.ready)
+		if b, ok := behaviour.(InitBehaviour); ok {
+			tryCatch(func() {
+				s.initErr = b.Init()
+			}, &s.initErr)
+		}
+		s.codec.ready.Store(s.initErr == nil)
+	})
+	return s.initErr
 }
 
 type genServerCodec struct {
-	requests  chan request
-	responses chan response
-	current   response
+	requests        mailbox
+	responses       chan response
+	responsesMu     sync.RWMutex
+	responsesClosed bool // guarded by `responsesMu`
+	current         response
+	ready           atomic.Bool // `Init` has completed successfully
+	closing         atomic.Bool // set by `PreClose`, new requests are rejected
+	quiesced        atomic.Bool // new requests are rejected, except for snapshots
+	handled         atomic.Uint64
+	failed          atomic.Uint64
+	inFlight        atomic.Int32              // requests being handled by the behaviour
+	outstanding     atomic.Int64              // requests put into the mailbox and not yet handled
+	abandoned       atomic.Int64              // abandoned calls whose responses haven't been drained yet
+	loop            atomic.Uint64             // id of the goroutine running the listen loop
+	tickPending     atomic.Bool               // a tick is in the mailbox
+	drainTo         atomic.Pointer[GenServer] // requests are forwarded to it, see `GenServer.DrainTo`
+	forwarding      sync.WaitGroup            // forwarded requests waiting for replies
+	progress        atomic.Uint64             // bumped whenever the listen loop dequeues or completes a request
+	handling        atomic.Pointer[string]    // service method being handled, nil between requests, see `WithDeadlockDetector`
+	done            chan struct{}
+	events          *eventStream
+	journal         *journal
+	flights         *flights
+	recorder        *recorder
+	memo            *memo
+	shedder         *shedder
+	cow             *cowState
+	replyWrapper    *replyWrapper // set by `Listen` before the listen loop starts, see `WrapReply`
+	reporting       *progressSink // progress of the request being handled, only touched by its handler
+	gate            *gate
+	options         options
 }
 
 var _ rpc.ClientCodec = (*genServerCodec)(nil)
 
 func (c *genServerCodec) WriteRequest(req *rpc.Request, body any) error {
 	var err error
+	env := body.(*envelope)
+	if c.closing.Load() {
+		return ErrServerClosing
+	}
+	if _, ok := env.args.(snapshot); !ok && c.quiesced.Load() {
+		return ErrServerClosing
+	}
+	serviceMethod := req.ServiceMethod
+	if normalize := c.options.methodNormalizer; normalize != nil && !internal(env.args) {
+		serviceMethod = normalize(serviceMethod)
+	}
+	if validate := c.options.validator; validate != nil && !internal(env.args) {
+		if err := validate(serviceMethod, env.args); err != nil {
+			return err
+		}
+	}
+	if c.options.initPolicy == InitReject && !c.ready.Load() {
+		return ErrNotReady
+	}
+	if env.cast && c.shedder.shed(float64(c.requests.len())/float64(max(c.requests.cap(), 1))) {
+		return ErrOverloaded
+	}
+	r := request{
+		seq:           req.Seq,
+		serviceMethod: serviceMethod,
+		body:          c.copy(env.args),
+		meta:          env.meta,
+		mode:          env.mode(),
+		enqueuedAt:    c.options.clock.Now(),
+		env:           env,
+	}
+	if c.flights.join(&r) {
+		env.enqueued = true
+		return nil
+	}
+	c.outstanding.Add(1) // before the request is visible to the listen loop
 	tryCatch(func() {
-		c.requests <- request{seq: req.Seq, serviceMethod: req.ServiceMethod, body: body}
+		err = c.enqueue(r)
 	}, &err)
+	if err != nil {
+		c.outstanding.Add(-1)
+		c.flights.land(r.flight)
+	}
+	if err != nil && c.closing.Load() {
+		return ErrServerClosing // the mailbox was closed under a blocked send
+	}
+	env.enqueued = err == nil
 	return err
 }
 
+// Puts the request into the mailbox. If the mailbox is full, the time spent waiting for a free slot
+// is reported to `Metrics.ObserveEnqueueBlock`
+func (c *genServerCodec) enqueue(req request) error {
+	if ok, err := c.requests.tryPut(req); ok || err != nil {
+		return err
+	}
+	if req.env.noWait {
+		return ErrMailboxFull
+	}
+	start := c.options.clock.Now()
+	if err := c.requests.put(req); err != nil {
+		return err
+	}
+	c.options.metrics.ObserveEnqueueBlock(c.options.clock.Now().Sub(start))
+	return nil
+}
+
 func (c *genServerCodec) ReadResponseHeader(res *rpc.Response) error {
 	response, ok := <-c.responses
 	if !ok {
+		c.abandoned.Store(0) // `rpc.Client` terminates the pending calls
 		return io.EOF
 	}
 	c.current = response
 	res.Seq = response.seq
 	res.ServiceMethod = response.serviceMethod
-	if response.Err() != nil {
-		res.Error = response.Err().Error()
+	if !response.env.deliver() {
+		c.abandoned.Add(-1)
+		c.options.orphanHandler(response.seq, response.serviceMethod, response.Value(), response.Err())
+		return nil
+	}
+	if err := response.Err(); err != nil {
+		// an error returned from `ReadResponseHeader` shuts down `rpc.Client`, so the error of the behaviour
+		// is reported as `rpc.ServerError` and the original one is kept for the caller
+		response.env.err = err
+		res.Error = err.Error()
 	}
-	return response.Err()
+	return nil
 }
 
 func (c *genServerCodec) ReadResponseBody(body any) error {
-	// if `ReadResponseHeader` DOES NOT return error then `ReadResponseBody` will be called => c.current.result.Err == nil
-	if c.current.Err() != nil {
-		log.Fatal("must be unreachable")
+	if c.current.Err() != nil || c.current.env.abandoned() {
+		return nil
 	}
 	v := c.current.Value()
 	if v == nil {
 		return nil
 	}
 	if body == nil { // should ignore nil `reply`
+		if c.options.captureNilReplies {
+			c.current.env.reply = v
+		}
 		return nil
 	}
 	tbody := reflect.TypeOf(body)
 	if tbody.Kind() != reflect.Pointer { // should ignore if `reply` non-pointer type
+		c.options.replyDiagnostics(fmt.Sprintf("reply of %q is not a pointer: %s", c.current.serviceMethod, tbody))
 		return nil
 	}
-	if tbody.Elem() != reflect.TypeOf(v) { // should ignore if `reply` has wrong type
+	if !reflect.TypeOf(v).AssignableTo(tbody.Elem()) { // should ignore if `reply` can't hold the value
+		c.options.replyDiagnostics(fmt.Sprintf("reply type mismatch of %q: %s can't hold %T", c.current.serviceMethod, tbody, v))
 		return nil
 	}
 	vbody := reflect.ValueOf(body)
-	vbody.Elem().Set(reflect.ValueOf(v))
+	vbody.Elem().Set(reflect.ValueOf(c.copy(v)))
 	return nil
 }
 
+// Applies `WithDeepCopy` to a value crossing the boundary of the server process
+func (c *genServerCodec) copy(v any) any {
+	if c.options.deepCopy == nil || v == nil {
+		return v
+	}
+	if internal(v) {
+		return v
+	}
+	return c.options.deepCopy(v)
+}
+
+// It's not part of `rpc.ClientCodec`.
+// Called before `Close`, so requests sent from now on and those blocked on a full mailbox
+// fail with `ErrServerClosing` instead of racing with the mailbox being closed
+func (c *genServerCodec) PreClose() {
+	c.closing.Store(true)
+}
+
 /**
  * Codec's `Close` method called by the `rpc.Client`
  * `rpc.Client` provides the following guaranties:
@@ -127,38 +1000,335 @@ func (c *genServerCodec) ReadResponseBody(body any) error {
  * - thread safety
  */
 func (c *genServerCodec) Close() error {
-	close(c.requests)
+	c.requests.close()
+	// waits for the response being sent, `rpc.Client` keeps reading responses until they are closed
+	c.responsesMu.Lock()
+	c.responsesClosed = true
 	close(c.responses)
+	c.responsesMu.Unlock()
+	close(c.done)
 	return nil
 }
 
-// It's not part of `rpc.ClientCodec`
-func (c *genServerCodec) Listen(behaviour Behaviour) {
+// It's not part of `rpc.ClientCodec`.
+// Returns a non-nil error if the server process must be terminated
+func (c *genServerCodec) Listen(behaviour Behaviour) error {
+	c.loop.Store(goid())
+	var postponed []request
+	busy := false
+	var served uint64 // requests of the behaviour taken from the mailbox, see `WithMaxRequests`
 	for {
-		req, ok := <-c.requests
+		req, ok := c.requests.get()
 		if !ok {
 			// rpc.Client.Close -> codec.Close() -> close(codec.requestsStream)
-			return
+			return nil
+		}
+		c.progress.Add(1)
+		if !busy && !internal(req.body) {
+			busy = true
+			c.options.onBusy()
 		}
+		c.gate.enter()
+		err := c.serve(behaviour, req, &postponed)
+		c.gate.leave()
+		if err != nil {
+			return err
+		}
+		if busy && c.outstanding.Load() == 0 {
+			busy = false
+			c.options.onIdle()
+		}
+		if !internal(req.body) {
+			served++
+			if limit := c.options.maxRequests; limit > 0 && served >= limit {
+				return ErrMaxRequests
+			}
+		}
+	}
+}
+
+// Handles the request taken from the mailbox and replays the postponed ones if it wasn't postponed itself
+func (c *genServerCodec) serve(behaviour Behaviour, req request, postponed *[]request) error {
+	isPostponed, err := c.handle(behaviour, req, postponed)
+	c.progress.Add(1)
+	c.outstanding.Add(-1)
+	if err != nil || isPostponed {
+		return err
+	}
+
+	// the request may have changed the state of the behaviour, so postponed requests get another chance
+	replay := *postponed
+	*postponed = nil
+	for _, req := range replay {
+		if _, err := c.handle(behaviour, req, postponed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		var v any
+// Handles the request and sends the response. Returns true if the behaviour postponed the request,
+// and a non-nil error if the server process must be terminated
+func (c *genServerCodec) handle(behaviour Behaviour, req request, postponed *[]request) (bool, error) {
+	if dst := c.drainTo.Load(); dst != nil {
+		for _, req := range *postponed {
+			c.forward(*dst, req)
+		}
+		*postponed = nil
+		if !internal(req.body) {
+			c.forward(*dst, req)
+			return false, nil
+		}
+	}
+	if _, ok := req.body.(tick); ok {
+		c.handleTick(behaviour)
+		return false, nil
+	}
+	if _, ok := req.body.(ping); ok {
+		c.handlePing(behaviour, req)
+		return false, nil
+	}
+	if f, ok := req.body.(snapshot); ok {
 		var err error
-		tryCatch(func() {
-			v, err = behaviour.Handle(req.serviceMethod, req.seq, req.body)
-		}, &err)
+		tryCatch(func() { f(behaviour) }, &err)
+		c.respond(req, nil, err)
+		return false, nil
+	}
 
-		var crucialErr error
-		tryCatch(func() {
-			c.responses <- response{
-				seq:           req.seq,
-				serviceMethod: req.serviceMethod,
-				result:        result[any]{Value: v, Error: err},
+	if ttl := c.options.messageTTL; ttl > 0 && c.options.clock.Now().Sub(req.enqueuedAt) > ttl {
+		c.reply(req, nil, ErrRequestExpired)
+		return false, nil
+	}
+
+	if req.postponed == 0 { // a postponed request has already been intercepted
+		if err := c.intercept(&req); errors.Is(err, Drop) {
+			// the duplicates waiting for the dropped request would otherwise never get a reply
+			for _, follower := range c.flights.land(req.flight) {
+				c.send(follower, nil, Drop)
 			}
-		}, &crucialErr)
+			return false, nil
+		} else if err != nil {
+			c.reply(req, nil, err)
+			return false, nil
+		}
+	}
 
-		if crucialErr != nil {
-			log.Print(crucialErr)
+	memoized, memoKey, hit := c.memo.lookup(req)
+	if hit {
+		c.reply(req, memoized, nil)
+		return false, nil
+	}
+
+	c.recorder.record(req)
+	var v any
+	var err, panicErr error
+	stop := c.watch(req)
+	detectDeadlocks := c.options.deadlockThreshold > 0
+	if detectDeadlocks {
+		method := req.serviceMethod // a copy, so only it is moved to the heap and only when it's needed
+		c.handling.Store(&method)
+	}
+	c.reporting = req.env.progress
+	c.inFlight.Add(1)
+	var timedOut bool
+	if budget := c.options.methodTimeouts[req.serviceMethod]; budget > 0 {
+		g := c.guard(behaviour, req, budget)
+		v, err, panicErr, timedOut = g.v, g.err, g.panicErr, g.timedOut
+	} else {
+		tryCatch(func() {
+			handlerFrame(func() { v, err = c.invoke(behaviour, req) })
+		}, &panicErr)
+	}
+	c.inFlight.Add(-1)
+	c.reporting = nil
+	if detectDeadlocks {
+		c.handling.Store(nil)
+	}
+	stop()
+
+	if timedOut {
+		switch {
+		case panicErr == nil:
+		case c.options.panicPolicy == PanicCrash:
+			panic(panicErr)
+		case c.options.panicPolicy == PanicRestart:
+			return false, panicErr
+		}
+		return false, nil
+	}
+	if panicErr != nil {
+		switch c.options.panicPolicy {
+		case PanicCrash:
+			panic(panicErr)
+		case PanicRestart:
+			c.reply(req, nil, panicErr)
+			return false, panicErr
 		}
+		err = panicErr
+	}
+
+	if errors.Is(err, Postpone) {
+		if req.postponed < c.options.postponeLimit {
+			req.postponed++
+			*postponed = append(*postponed, req)
+			return true, nil
+		}
+		err = ErrPostponeLimit
+	}
+	if err == nil {
+		c.memo.store(memoKey, v)
+	} else {
+		var redirect Redirect
+		if errors.As(err, &redirect) && redirect.To != nil {
+			c.redirect(req, redirect.To)
+			return false, nil
+		}
+		c.options.onHandlerError(req.serviceMethod, req.seq, err)
+	}
+	c.reply(req, v, err)
+	return false, nil
+}
+
+func (c *genServerCodec) invoke(behaviour Behaviour, req request) (any, error) {
+	if c.options.knownMethods != nil {
+		if _, ok := c.options.knownMethods[req.serviceMethod]; !ok {
+			if b, ok := behaviour.(FallbackBehaviour); ok {
+				return b.HandleUnknown(req.serviceMethod, req.seq, req.body)
+			}
+			return nil, ErrUnknownMethod
+		}
+	}
+	if c.cow != nil {
+		return c.cow.handle(req)
+	}
+	switch b := behaviour.(type) {
+	case CancelableBehaviour:
+		return b.HandleCancelable(req.serviceMethod, req.seq, req.body, req.env.cancel)
+	case ContextBehaviour:
+		return b.HandleContext(req.env.context(), req.serviceMethod, req.seq, req.body)
+	case TimedBehaviour:
+		return b.HandleTimed(req.serviceMethod, req.seq, req.body, req.enqueuedAt)
+	case ModeBehaviour:
+		return b.HandleMode(req.mode, req.serviceMethod, req.seq, req.body)
+	}
+	return behaviour.Handle(req.serviceMethod, req.seq, req.body)
+}
+
+// Runs the interceptor set by `WithRequestInterceptor` and applies its changes to the request
+func (c *genServerCodec) intercept(req *request) error {
+	if c.options.interceptor == nil {
+		return nil
+	}
+	r := req.export()
+	err := c.options.interceptor(&r)
+	req.serviceMethod, req.body, req.meta = r.ServiceMethod, r.Body, r.Meta
+	return err
+}
+
+// Runs the handler of a method with a budget set by `WithMethodTimeout` on another goroutine.
+// When the budget is exceeded the caller gets `ErrMethodTimeout` right away, but the listen loop still waits
+// for the handler to return, so requests are never handled concurrently. Kept apart from `handle`,
+// so requests without a budget don't pay for the goroutine and the captured results
+func (c *genServerCodec) guard(behaviour Behaviour, req request, budget time.Duration) guarded {
+	loop := c.loop.Load()
+	defer c.loop.Store(loop)
+	var g guarded
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.loop.Store(goid()) // the handler stands in for the listen loop, see `reentrant`
+		tryCatch(func() {
+			handlerFrame(func() { g.v, g.err = c.invoke(behaviour, req) })
+		}, &g.panicErr)
+	}()
+	fired, stop := timer(c.options.clock, budget)
+	select {
+	case <-done:
+		stop()
+		return g
+	case <-fired:
+	}
+	c.options.onHandlerError(req.serviceMethod, req.seq, ErrMethodTimeout)
+	c.reply(req, nil, ErrMethodTimeout)
+	<-done
+	g.timedOut = true
+	return g
+}
+
+// Outcome of a handler run by `guard`
+type guarded struct {
+	v        any
+	err      error
+	panicErr error // recovered from the handler
+	timedOut bool  // the caller has already got `ErrMethodTimeout`
+}
+
+// Reports whether the caller is the behaviour itself, so a blocking call would wait for the listen loop forever.
+// The goroutine is only inspected while a request is being handled, and identified only if it runs a handler
+func (c *genServerCodec) reentrant() bool {
+	return c.inFlight.Load() > 0 && onHandler() && c.loop.Load() == goid()
+}
+
+// Same as `reentrant` for blocking calls, which aren't checked if `WithoutReentrancyCheck` is set
+func (c *genServerCodec) reentrantCall() bool {
+	return !c.options.noReentrancyCheck && c.reentrant()
+}
+
+// Starts the handler watchdog for the request. The returned function stops it
+func (c *genServerCodec) watch(req request) func() {
+	if c.options.watchdogTimeout <= 0 {
+		return func() {}
+	}
+	serviceMethod, seq := req.serviceMethod, req.seq
+	stop := c.options.clock.AfterFunc(c.options.watchdogTimeout, func() {
+		c.options.onStuck(serviceMethod, seq)
+	})
+	return func() { stop() }
+}
+
+func (c *genServerCodec) reply(req request, v any, err error) {
+	if req.env != nil {
+		req.env.progress.close() // before the response, so the caller sees the last report first
+	}
+	if !internal(req.body) {
+		v, err = c.replyWrapper.apply(req.serviceMethod, req.seq, v, err)
+	}
+	// emitted before the response, so the event precedes anything the caller does after getting it
+	c.events.emit(Event{Kind: EventHandledRequest, Seq: req.seq, ServiceMethod: req.serviceMethod})
+	c.journal.record(RequestLog{Seq: req.seq, ServiceMethod: req.serviceMethod, Err: err})
+	c.handled.Add(1)
+	if err != nil {
+		c.failed.Add(1)
+	}
+	c.respond(req, v, err)
+}
+
+// Sends the response to the caller and to the callers of duplicate requests, see `WithSingleFlight`
+func (c *genServerCodec) respond(req request, v any, err error) {
+	c.send(req, v, err)
+	for _, follower := range c.flights.land(req.flight) {
+		c.send(follower, v, err)
+	}
+}
+
+// Responses of requests handled after `Close` are dropped, their callers have already got `rpc.ErrShutdown`
+func (c *genServerCodec) send(req request, v any, err error) {
+	if err == nil {
+		c.ack(req)
+	}
+	if c.options.replyIsolation {
+		v = clone(v) // on the server process, the behaviour can't mutate the value while it's copied
+	}
+	c.responsesMu.RLock()
+	defer c.responsesMu.RUnlock()
+	if c.responsesClosed {
+		return
+	}
+	c.responses <- response{
+		seq:           req.seq,
+		serviceMethod: req.serviceMethod,
+		result:        result[any]{Value: v, Error: err},
+		env:           req.env,
 	}
 }
 
@@ -166,12 +1336,111 @@ type request struct {
 	seq           uint64
 	serviceMethod string
 	body          any
+	meta          Meta
+	mode          Mode
+	enqueuedAt    time.Time
+	env           *envelope
+	postponed     int
+	flight        *flight // non-nil if the request leads a flight of duplicates
+}
+
+func (r request) export() Request {
+	return Request{Seq: r.seq, ServiceMethod: r.serviceMethod, Body: r.body, Meta: r.meta, Mode: r.mode}
+}
+
+// Request as seen by the hooks of a server process
+type Request struct {
+	Seq           uint64
+	ServiceMethod string
+	Body          any
+	Meta          Meta
+	Mode          Mode
+}
+
+// Caller-supplied attributes of a request, see `GenServer.CallWithMeta`
+type Meta struct {
+	// Identifies the tenant on whose behalf the request is sent, see `WithFairQueuing`
+	Tenant string
+	// Requests with higher priority are handled first, see `WithPriorityQueue`
+	Priority int
+	// Number of times the request has been redirected to another server process, set by the server process, see `Redirect`
+	Redirects int
 }
 
 type response struct {
 	seq           uint64
 	serviceMethod string
 	result        result[any]
+	env           *envelope
+}
+
+// Internal request that is handled by the server process itself, see `GenServer.Snapshot`
+type snapshot func(Behaviour)
+
+// Per-call state shared by the caller and the codec. It's passed to `rpc.Client` in place of the arguments
+type envelope struct {
+	args   any
+	meta   Meta
+	err    error // original error returned by the behaviour
+	state  atomic.Int32
+	cancel chan struct{}   // closed when the caller abandons the envelope, nil if the call can't be abandoned
+	ctx    context.Context // context of the caller, nil if the call has none
+	reply  any             // value the caller had no `reply` for, see `WithCaptureNilReplies`
+	// fail with `ErrMailboxFull` instead of waiting for a free slot, see `GenServer.CastAck`
+	noWait   bool
+	enqueued bool // set by `WriteRequest` on the caller's goroutine
+	cast     bool // the caller doesn't block on the reply, see `WithAdaptiveShedding` and `Mode`
+	acked    bool // acknowledged in the delivery journal once handled, see `GenServer.CastReliable`
+	ackID    uint64
+	progress *progressSink // reports of the handler, see `GenServer.CallWithProgress`
+}
+
+const (
+	envelopePending int32 = iota
+	envelopeDelivered
+	envelopeAbandoned
+)
+
+// Claims the envelope for the response. Returns false if the caller has abandoned it
+func (e *envelope) deliver() bool {
+	return e.state.CompareAndSwap(envelopePending, envelopeDelivered)
+}
+
+// Claims the envelope for the caller. Returns false if the response is already being delivered
+func (e *envelope) abandon() bool {
+	if !e.state.CompareAndSwap(envelopePending, envelopeAbandoned) {
+		return false
+	}
+	if e.cancel != nil {
+		close(e.cancel)
+	}
+	return true
+}
+
+func (e *envelope) abandoned() bool {
+	return e.state.Load() == envelopeAbandoned
+}
+
+func (e *envelope) mode() Mode {
+	if e.cast {
+		return ModeCast
+	}
+	return ModeCall
+}
+
+func (e *envelope) context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+// Prefers the original error of the behaviour over the one reported by `rpc.Client`
+func (e *envelope) error(err error) error {
+	if e.err != nil {
+		return e.err
+	}
+	return err
 }
 
 func (r response) Value() any {
@@ -1,8 +1,18 @@
 package genserver
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"log"
 	"net/rpc"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -23,31 +33,1800 @@ func TestGenServer(t *testing.T) {
 		assert.ErrorIs(t, err, expectedErr)
 	})
 
-	t.Run("should recover from panic when sending to closed mailbox of server process", func(t *testing.T) {
+	t.Run("should preserve chain of wrapped error returned by behaviour", func(t *testing.T) {
+		// arrange
+		cause := &ValidationError{Field: "name"}
+		s := Listen(func(genserv GenServer) *FailingServer {
+			return &FailingServer{GenServer: genserv, err: fmt.Errorf("put: %w", cause)}
+		})
+		defer s.Close()
+
+		// act
+		err := s.Call("put", nil, nil)
+		call := s.Cast("put", nil, nil, nil)
+		<-call.Done
+
+		// assert
+		for _, err := range []error{err, call.Error} {
+			var validationErr *ValidationError
+			assert.ErrorIs(t, err, cause)
+			assert.True(t, errors.As(err, &validationErr))
+			assert.Equal(t, "name", validationErr.Field)
+			assert.EqualError(t, err, "put: invalid name")
+		}
+	})
+
+	t.Run("should reject request blocked on full mailbox when server is closing", func(t *testing.T) {
 		// arrange
 		s := NewEchoServer(1 * time.Hour)
 
 		// act
-		call1 := s.Cast("", "foo", nil, nil)
-		go func() {
-			time.Sleep(200 * time.Millisecond)
-			s.Close()
-		}()
-		call2 := s.Cast("", "bar", nil, nil)
-		<-call2.Done
-		<-call1.Done
+		call1 := s.Cast("", "foo", nil, nil)
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			s.Close()
+		}()
+		call2 := s.Cast("", "bar", nil, nil)
+		<-call2.Done
+		<-call1.Done
+
+		// assert
+		assert.ErrorIs(t, call1.Error, rpc.ErrShutdown)
+		assert.ErrorIs(t, call2.Error, ErrServerClosing)
+	})
+
+	t.Run("should drop response of request handled after close", func(t *testing.T) {
+		// arrange
+		var logs bytes.Buffer
+		log.SetOutput(&logs)
+		defer log.SetOutput(os.Stderr)
+		s := NewEchoServer(100 * time.Millisecond)
+		call := s.Cast("echo", "foo", nil, nil)
+		for s.InFlight() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		// act
+		s.Close()
+		<-call.Done
+		for s.Stats().Handled == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond) // the response is sent after the request is counted
+
+		// assert
+		assert.ErrorIs(t, call.Error, rpc.ErrShutdown)
+		assert.Empty(t, logs.String())
+	})
+
+	t.Run("should reject request sent while server is closing", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+
+		// act
+		s.GenServer.(*genServer).codec.PreClose()
+		call := s.Cast("echo", "foo", nil, nil)
+		<-call.Done
+
+		// assert
+		assert.ErrorIs(t, call.Error, ErrServerClosing)
+	})
+
+	t.Run("should observe enqueue block duration when mailbox is full", func(t *testing.T) {
+		// arrange
+		metrics := &metricsRecorder{}
+		s := NewEchoServer(200*time.Millisecond, WithMetrics(metrics))
+		defer s.Close()
+
+		// act
+		call1 := s.Cast("", "foo", nil, nil)
+		call2 := s.Cast("", "bar", nil, nil)
+		<-call1.Done
+		<-call2.Done
+
+		// assert
+		assert.GreaterOrEqual(t, metrics.MaxEnqueueBlock(), 100*time.Millisecond)
+	})
+
+	t.Run("should close server only once", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+
+		// act
+		err1 := s.Close()
+		err2 := s.Close()
+
+		// assert
+		assert.Nil(t, err1)
+		assert.Nil(t, err2)
+		assert.ErrorIs(t, s.Call("echo", nil, nil), rpc.ErrShutdown)
+	})
+
+	t.Run("should close done channel when server terminates", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+
+		// act
+		s.Close()
+
+		// assert
+		select {
+		case <-s.Done():
+		case <-time.After(time.Second):
+			t.Fatal("done channel is not closed")
+		}
+	})
+}
+
+func TestPanicPolicy(t *testing.T) {
+	t.Run("should convert panic to error and continue to handle requests", func(t *testing.T) {
+		// arrange
+		expectedErr := errors.New("something went wrong")
+		s := NewPanicServer(expectedErr, WithPanicPolicy(PanicIsolate))
+		defer s.Close()
+
+		// act
+		err1 := s.Call("", nil, nil)
+		err2 := s.Call("", nil, nil)
+
+		// assert
+		assert.ErrorIs(t, err1, expectedErr)
+		assert.ErrorIs(t, err2, expectedErr)
+	})
+
+	t.Run("should reply with error and terminate server process", func(t *testing.T) {
+		// arrange
+		expectedErr := errors.New("something went wrong")
+		s := NewPanicServer(expectedErr, WithPanicPolicy(PanicRestart))
+
+		// act
+		err := s.Call("", nil, nil)
+		<-s.Done()
+
+		// assert
+		assert.ErrorIs(t, err, expectedErr)
+		assert.ErrorIs(t, s.Call("", nil, nil), rpc.ErrShutdown)
+	})
+
+	t.Run("should propagate panic", func(t *testing.T) {
+		// arrange
+		expectedErr := errors.New("something went wrong")
+		genserv := NewGenServer(WithPanicPolicy(PanicCrash))
+		defer genserv.Close()
+		s := &PanicServer{GenServer: genserv, err: expectedErr}
+		s.Cast("", nil, nil, nil)
+
+		// act
+		var info any
+		func() {
+			defer func() { info = recover() }()
+			genserv.Listen(s) // run the loop on the current goroutine to catch the panic
+		}()
+
+		// assert
+		assert.ErrorIs(t, info.(error), expectedErr)
+	})
+}
+
+func TestCallContext(t *testing.T) {
+	t.Run("should return reply if context is not done", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+
+		// act
+		var reply string
+		err := s.CallContext(context.Background(), "echo", "foo", &reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "foo", reply)
+	})
+
+	t.Run("should stop waiting and route late response to orphan handler", func(t *testing.T) {
+		// arrange
+		type orphan struct {
+			serviceMethod string
+			value         any
+			err           error
+		}
+		orphans := make(chan orphan, 1)
+		s := NewEchoServer(200*time.Millisecond, WithOrphanHandler(func(_ uint64, serviceMethod string, value any, err error) {
+			orphans <- orphan{serviceMethod, value, err}
+		}))
+		defer s.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// act
+		var reply string
+		err := s.CallContext(ctx, "echo", "foo", &reply)
+		late := <-orphans
+
+		// assert
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, orphan{"echo", "foo", nil}, late)
+		assert.Empty(t, reply)
+	})
+
+	t.Run("should not send request if context is already done", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// act
+		err := s.CallContext(ctx, "echo", "foo", nil)
+
+		// assert
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("should let handler observe that the caller stopped waiting", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *CancelableServer {
+			return &CancelableServer{GenServer: genserv}
+		})
+		defer s.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// act
+		err := s.CallContext(ctx, "work", nil, nil)
+		var cancelled bool
+		s.Snapshot(func(Behaviour) { cancelled = s.cancelled })
+
+		// assert
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, cancelled)
+	})
+}
+
+func TestContextPropagation(t *testing.T) {
+	t.Run("should abandon nested call when the outer one is abandoned", func(t *testing.T) {
+		// arrange
+		inner := Listen(func(genserv GenServer) *CancelableServer {
+			return &CancelableServer{GenServer: genserv}
+		})
+		defer inner.Close()
+		outer := Listen(func(genserv GenServer) *ChainServer {
+			return &ChainServer{GenServer: genserv, next: inner}
+		})
+		defer outer.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// act
+		err := outer.CallContext(ctx, "work", nil, nil)
+		var cancelled bool
+		inner.Snapshot(func(Behaviour) { cancelled = inner.cancelled })
+
+		// assert
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, cancelled)
+	})
+}
+
+func TestHandlerWatchdog(t *testing.T) {
+	type stuck struct {
+		serviceMethod string
+		seq           uint64
+	}
+
+	t.Run("should report handler running longer than allowed", func(t *testing.T) {
+		// arrange
+		stucks := make(chan stuck, 2)
+		s := NewEchoServer(200*time.Millisecond, WithHandlerWatchdog(50*time.Millisecond, func(serviceMethod string, seq uint64) {
+			stucks <- stuck{serviceMethod, seq}
+		}))
+		defer s.Close()
+
+		// act
+		s.Call("echo", nil, nil)
+		s.Call("slow", nil, nil)
+
+		// assert
+		assert.Equal(t, stuck{"echo", 0}, <-stucks)
+		assert.Equal(t, stuck{"slow", 1}, <-stucks)
+	})
+
+	t.Run("should not report handler completed in time", func(t *testing.T) {
+		// arrange
+		stucks := make(chan stuck, 1)
+		s := NewEchoServer(0, WithHandlerWatchdog(50*time.Millisecond, func(serviceMethod string, seq uint64) {
+			stucks <- stuck{serviceMethod, seq}
+		}))
+		defer s.Close()
+
+		// act
+		s.Call("echo", nil, nil)
+		time.Sleep(100 * time.Millisecond)
+
+		// assert
+		assert.Empty(t, stucks)
+	})
+
+	t.Run("should measure handler by clock of server process", func(t *testing.T) {
+		// arrange
+		clock := NewFakeClock()
+		stucks := make(chan stuck, 1)
+		s := NewEchoServer(200*time.Millisecond, WithClock(clock), WithHandlerWatchdog(time.Hour, func(serviceMethod string, seq uint64) {
+			stucks <- stuck{serviceMethod, seq}
+		}))
+		defer s.Close()
+
+		// act
+		s.Cast("echo", nil, nil, nil)
+		<-clock.waiters
+		clock.Advance(time.Hour)
+
+		// assert
+		assert.Equal(t, stuck{"echo", 0}, <-stucks)
+	})
+}
+
+func TestMethodTimeout(t *testing.T) {
+	t.Run("should fail slow method and keep handling requests in order", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv, delay: 100 * time.Millisecond}
+		}, WithMethodTimeout(map[string]time.Duration{"slow": 20 * time.Millisecond}))
+		defer s.Close()
+
+		// act
+		start := time.Now()
+		err := s.Call("slow", "foo", nil)
+		elapsed := time.Since(start)
+		var reply string
+		err2 := s.Call("fast", "bar", &reply)
+
+		// assert
+		assert.ErrorIs(t, err, ErrMethodTimeout)
+		assert.Less(t, elapsed, 100*time.Millisecond)
+		assert.Nil(t, err2)
+		assert.Equal(t, "bar", reply)
+	})
+
+	t.Run("should reply if method completes within budget", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv, delay: 10 * time.Millisecond}
+		}, WithMethodTimeout(map[string]time.Duration{"echo": time.Second}))
+		defer s.Close()
+
+		// act
+		var reply string
+		err := s.Call("echo", "foo", &reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "foo", reply)
+	})
+
+	t.Run("should detect reentrant call from guarded handler", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *ReentrantServer {
+			return &ReentrantServer{GenServer: genserv}
+		}, WithMethodTimeout(map[string]time.Duration{"reenter": time.Second}))
+		defer s.Close()
+
+		// act
+		err := s.Call("reenter", "foo", nil)
+
+		// assert
+		assert.ErrorIs(t, err, ErrReentrantCall)
+	})
+}
+
+func TestTimedBehaviour(t *testing.T) {
+	t.Run("should expose time spent in the mailbox", func(t *testing.T) {
+		// arrange
+		s := Build(func(genserv GenServer) *QueueWaitServer {
+			return &QueueWaitServer{GenServer: genserv}
+		})
+		defer s.Close()
+
+		// act
+		var wait time.Duration
+		call := s.Cast("wait", nil, &wait, nil)
+		time.Sleep(100 * time.Millisecond) // back up the mailbox
+		s.Start()
+		<-call.Done
+
+		// assert
+		assert.Nil(t, call.Error)
+		assert.GreaterOrEqual(t, wait, 100*time.Millisecond)
+	})
+}
+
+func TestModeBehaviour(t *testing.T) {
+	t.Run("should reject cast of method that needs confirmed reply", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *ModeServer {
+			return &ModeServer{GenServer: genserv}
+		})
+		defer s.Close()
+
+		// act
+		call := <-s.Cast("delete", "foo", nil, nil).Done
+		err := s.CastAck("delete", "bar")
+		var deleted string
+		err2 := s.Call("delete", "baz", &deleted)
+
+		// assert
+		assert.ErrorIs(t, call.Error, ErrMustUseCall)
+		assert.Nil(t, err) // the reply of `CastAck` is dropped
+		assert.Nil(t, err2)
+		assert.Equal(t, "baz", deleted)
+		assert.Equal(t, []string{"baz"}, s.deleted)
+	})
+
+	t.Run("should keep mode of forwarded calls", func(t *testing.T) {
+		// arrange
+		dst := Listen(func(genserv GenServer) *ModeServer {
+			return &ModeServer{GenServer: genserv}
+		})
+		defer dst.Close()
+		shard := Listen(func(genserv GenServer) *ShardServer {
+			return &ShardServer{GenServer: genserv, owners: map[string]GenServer{"foo": dst}}
+		})
+		defer shard.Close()
+		src := Listen(func(genserv GenServer) *ReplicaServer {
+			return &ReplicaServer{GenServer: genserv, delay: 50 * time.Millisecond}
+		})
+		src.Cast("get", nil, nil, nil)
+		time.Sleep(10 * time.Millisecond) // the first request is being handled
+		queued := make(chan error, 1)
+		go func() {
+			queued <- src.Call("delete", "bar", nil)
+		}()
+		time.Sleep(10 * time.Millisecond) // the call is in the mailbox
+
+		// act
+		redirected := shard.Call("delete", "foo", nil)
+		drained := src.DrainTo(dst)
+		results := CallAll(dst, []Request{{ServiceMethod: "delete", Body: "baz"}}, 0)
+		cast := <-shard.Cast("delete", "foo", nil, nil).Done
+
+		// assert
+		assert.Nil(t, redirected)
+		assert.Nil(t, drained)
+		assert.Nil(t, <-queued)
+		assert.Nil(t, results[0].Error)
+		assert.ErrorIs(t, cast.Error, ErrMustUseCall)
+		assert.Equal(t, []string{"foo", "bar", "baz"}, dst.deleted)
+	})
+
+	t.Run("should not carry mode of forwarded call in meta seen by hooks", func(t *testing.T) {
+		// arrange
+		var seen Meta
+		dst := Listen(func(genserv GenServer) *ModeServer {
+			return &ModeServer{GenServer: genserv}
+		}, WithRequestInterceptor(func(r *Request) error {
+			seen = r.Meta
+			return nil
+		}))
+		defer dst.Close()
+		CallAll(dst, []Request{{ServiceMethod: "delete", Body: "foo"}}, 0)
+
+		// act
+		cast := <-dst.CastWithMeta("delete", "bar", nil, nil, seen).Done
+
+		// assert
+		assert.ErrorIs(t, cast.Error, ErrMustUseCall)
+		assert.Equal(t, []string{"foo"}, dst.deleted)
+	})
+
+	t.Run("should expose mode to hooks", func(t *testing.T) {
+		// arrange
+		var modes []Mode
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		}, WithFairQueuing(func(r Request) string {
+			modes = append(modes, r.Mode)
+			return ""
+		}))
+		defer s.Close()
+
+		// act
+		s.Call("echo", 1, nil)
+		<-s.Cast("echo", 2, nil, nil).Done
+
+		// assert
+		assert.Equal(t, []Mode{ModeCall, ModeCast}, modes)
+	})
+}
+
+func TestDeepCopy(t *testing.T) {
+	copyFn := func(v any) any {
+		if s, ok := v.([]int); ok {
+			return append([]int(nil), s...)
+		}
+		return v
+	}
+
+	t.Run("should not let handler mutate the caller's request body", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *MutatingServer {
+			return &MutatingServer{GenServer: genserv}
+		}, WithDeepCopy(copyFn))
+		defer s.Close()
+		body := []int{1, 2, 3}
+
+		// act
+		var reply []int
+		err := s.Call("mutate", body, &reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, []int{1, 2, 3}, body)
+		assert.Equal(t, []int{-1, 2, 3}, reply)
+	})
+
+	t.Run("should not let caller mutate the state of behaviour via reply", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *MutatingServer {
+			return &MutatingServer{GenServer: genserv}
+		}, WithDeepCopy(copyFn))
+		defer s.Close()
+		s.Call("mutate", []int{1, 2, 3}, nil)
+
+		// act
+		var reply []int
+		s.Call("last", nil, &reply)
+		reply[1] = -2
+		var last []int
+		err := s.Snapshot(func(Behaviour) { last = s.last })
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, []int{-1, 2, 3}, last)
+	})
+
+	t.Run("should pass values by reference by default", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *MutatingServer {
+			return &MutatingServer{GenServer: genserv}
+		})
+		defer s.Close()
+		body := []int{1, 2, 3}
+
+		// act
+		err := s.Call("mutate", body, nil)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, []int{-1, 2, 3}, body)
+	})
+}
+
+func TestCallInto(t *testing.T) {
+	t.Run("should overwrite reused reply", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+
+		// act + assert
+		var reply int
+		for i := 0; i < 3; i++ {
+			err := s.CallInto("echo", i, &reply)
+			assert.Nil(t, err)
+			assert.Equal(t, i, reply)
+		}
+	})
+}
+
+func TestCallTimed(t *testing.T) {
+	t.Run("should return round-trip time of the call", func(t *testing.T) {
+		// arrange
+		delay := 50 * time.Millisecond
+		s := NewEchoServer(delay)
+		defer s.Close()
+
+		// act
+		var reply int
+		elapsed, err := s.CallTimed("echo", 1, &reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 1, reply)
+		assert.GreaterOrEqual(t, elapsed, delay)
+		assert.Less(t, elapsed, delay+time.Second)
+	})
+}
+
+func TestReentrantCall(t *testing.T) {
+	t.Run("should return error instead of deadlock when handler calls its own server", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *ReentrantServer {
+			return &ReentrantServer{GenServer: genserv}
+		})
+		defer s.Close()
+
+		// act
+		err := s.Call("reenter", nil, nil)
+
+		// assert
+		assert.ErrorIs(t, err, ErrReentrantCall)
+	})
+
+	t.Run("should tell handler goroutine by its call stack", func(t *testing.T) {
+		// act
+		var inside bool
+		handlerFrame(func() { inside = onHandler() })
+
+		// assert
+		assert.True(t, inside)
+		assert.False(t, onHandler())
+	})
+
+	t.Run("should not reject calls from other goroutines while a request is handled", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(100 * time.Millisecond)
+		defer s.Close()
+
+		// act
+		call := s.Cast("echo", "foo", nil, nil)
+		err := s.Call("echo", "bar", nil)
+		<-call.Done
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, call.Error)
+	})
+}
+
+func TestCallFull(t *testing.T) {
+	t.Run("should return completed call matching the request", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+
+		// act
+		var reply string
+		call, err := s.CallFull("echo", "foo", &reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, call.Error)
+		assert.Equal(t, "echo", call.ServiceMethod)
+		assert.Equal(t, "foo", call.Args)
+		assert.Same(t, &reply, call.Reply)
+		assert.Equal(t, "foo", Reply[string](call))
+	})
+
+	t.Run("should return error of the behaviour both ways", func(t *testing.T) {
+		// arrange
+		expectedErr := errors.New("something went wrong")
+		s := NewPanicServer(expectedErr)
+		defer s.Close()
+
+		// act
+		call, err := s.CallFull("", nil, nil)
+
+		// assert
+		assert.ErrorIs(t, err, expectedErr)
+		assert.ErrorIs(t, call.Error, expectedErr)
+	})
+}
+
+func TestInterfaceReply(t *testing.T) {
+	t.Run("should assign concrete value to interface reply it implements", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+		expected := &ValidationError{Field: "name"}
+
+		// act
+		var reply error
+		err := s.Call("echo", expected, &reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Same(t, expected, reply)
+	})
+
+	t.Run("should ignore interface reply the value doesn't implement", func(t *testing.T) {
+		// arrange
+		var warnings []string
+		s := NewEchoServer(0, WithReplyDiagnostics(func(warning string) { warnings = append(warnings, warning) }))
+		defer s.Close()
+
+		// act
+		var reply fmt.Stringer
+		err := s.Call("echo", &ValidationError{Field: "name"}, &reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, reply)
+		assert.Len(t, warnings, 1)
+	})
+}
+
+func TestCaptureNilReplies(t *testing.T) {
+	t.Run("should keep reply value of cast with nil reply", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0, WithCaptureNilReplies())
+		defer s.Close()
+
+		// act
+		call := s.Cast("echo", "foo", nil, nil)
+		<-call.Done
+
+		// assert
+		assert.Nil(t, call.Error)
+		assert.Equal(t, "foo", call.Reply)
+	})
+
+	t.Run("should drop reply value by default", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+
+		// act
+		call := s.Cast("echo", "foo", nil, nil)
+		<-call.Done
+
+		// assert
+		assert.Nil(t, call.Error)
+		assert.Nil(t, call.Reply)
+	})
+}
+
+func TestCastAck(t *testing.T) {
+	t.Run("should return once request is enqueued without waiting for slow handler", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv, delay: time.Second}
+		})
+		defer s.Close()
+
+		// act
+		start := time.Now()
+		err1 := s.CastAck("echo", "foo")
+		err2 := s.CastAck("echo", "bar")
+
+		// assert
+		assert.Nil(t, err1)
+		assert.Nil(t, err2)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("should fail instead of waiting when mailbox is full", func(t *testing.T) {
+		// arrange
+		genserv := newGenServer(1, 0)
+		s := &EchoServer{GenServer: genserv, delay: time.Second}
+		genserv.setBehaviour(s)
+		defer s.Close()
+
+		// act
+		err1 := s.CastAck("echo", "foo")
+		err2 := s.CastAck("echo", "bar")
+
+		// assert
+		assert.Nil(t, err1)
+		assert.ErrorIs(t, err2, ErrMailboxFull)
+	})
+
+	t.Run("should fail when server is closed", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		s.Close()
+
+		// act
+		err := s.CastAck("echo", "foo")
+
+		// assert
+		assert.ErrorIs(t, err, rpc.ErrShutdown)
+	})
+}
+
+func TestCastCallback(t *testing.T) {
+	t.Run("should invoke callback with completed call", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+		completed := make(chan *rpc.Call, 1)
+
+		// act
+		var reply string
+		s.CastCallback("echo", "foo", &reply, func(call *rpc.Call) { completed <- call })
+		call := <-completed
+
+		// assert
+		assert.Nil(t, call.Error)
+		assert.Equal(t, "echo", call.ServiceMethod)
+		assert.Equal(t, "foo", reply)
+		assert.Equal(t, "foo", Reply[string](call))
+	})
+
+	t.Run("should invoke callback once when server is closed", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		s.Close()
+		var calls atomic.Int32
+		completed := make(chan *rpc.Call, 2)
+
+		// act
+		s.CastCallback("echo", "foo", nil, func(call *rpc.Call) {
+			calls.Add(1)
+			completed <- call
+		})
+		call := <-completed
+		time.Sleep(50 * time.Millisecond)
+
+		// assert
+		assert.ErrorIs(t, call.Error, rpc.ErrShutdown)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+}
+
+func TestReplyDiagnostics(t *testing.T) {
+	t.Run("should report reply that is not a pointer", func(t *testing.T) {
+		// arrange
+		warnings := make(chan string, 1)
+		s := NewEchoServer(0, WithReplyDiagnostics(func(warning string) { warnings <- warning }))
+		defer s.Close()
+
+		// act
+		var reply int
+		err := s.Call("echo", 1, reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 0, reply)
+		assert.Equal(t, `reply of "echo" is not a pointer: int`, <-warnings)
+	})
+
+	t.Run("should report reply of wrong type", func(t *testing.T) {
+		// arrange
+		warnings := make(chan string, 1)
+		s := NewEchoServer(0, WithReplyDiagnostics(func(warning string) { warnings <- warning }))
+		defer s.Close()
+
+		// act
+		var reply string
+		err := s.Call("echo", 1, &reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Empty(t, reply)
+		assert.Equal(t, `reply type mismatch of "echo": *string can't hold int`, <-warnings)
+	})
+}
+
+func TestRequestValidator(t *testing.T) {
+	errEmptyKey := errors.New("empty key")
+	validate := func(serviceMethod string, body any) error {
+		if kvp, ok := body.(KeyValuePair); ok && serviceMethod == "put" && kvp.Key == "" {
+			return errEmptyKey
+		}
+		return nil
+	}
+
+	t.Run("should reject invalid request before it reaches the mailbox", func(t *testing.T) {
+		// arrange
+		s := NewLazyStoreServer(WithRequestValidator(validate))
+		defer s.Close()
+
+		// act
+		err := s.Call("put", KeyValuePair{"", 1}, nil)
+		var size int
+		s.Snapshot(func(Behaviour) { size = len(s.data) })
+
+		// assert
+		assert.ErrorIs(t, err, errEmptyKey)
+		assert.Zero(t, size)
+	})
+
+	t.Run("should pass valid request to behaviour", func(t *testing.T) {
+		// arrange
+		s := NewLazyStoreServer(WithRequestValidator(validate))
+		defer s.Close()
+
+		// act
+		err := s.Call("put", KeyValuePair{"one", 1}, nil)
+		var v int
+		err2 := s.Call("get", "one", &v)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, err2)
+		assert.Equal(t, 1, v)
+	})
+}
+
+func TestPrepareShutdown(t *testing.T) {
+	t.Run("should keep server process running until state is flushed", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *BufferedServer {
+			return &BufferedServer{GenServer: genserv}
+		})
+		s.Call("write", 1, nil)
+
+		// act
+		err := s.Close()
+		err2 := s.Call("flush", nil, nil)
+		err3 := s.Close()
+		<-s.Done()
+
+		// assert
+		assert.ErrorIs(t, err, ErrUnflushed)
+		assert.Nil(t, err2)
+		assert.Nil(t, err3)
+	})
+
+	t.Run("should consult behaviour of server process that hasn't started", func(t *testing.T) {
+		// arrange
+		s := Build(func(genserv GenServer) *BufferedServer {
+			return &BufferedServer{GenServer: genserv, pending: []any{1}}
+		})
+
+		// act
+		err := s.Close()
+		s.pending = nil
+		err2 := s.Close()
+
+		// assert
+		assert.ErrorIs(t, err, ErrUnflushed)
+		assert.Nil(t, err2)
+	})
+
+	t.Run("should not consult behaviour when server process crashes", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *BufferedServer {
+			return &BufferedServer{GenServer: genserv}
+		}, WithPanicPolicy(PanicRestart))
+		s.Call("write", 1, nil)
+
+		// act
+		s.Cast("crash", nil, nil, nil)
+
+		// assert
+		select {
+		case <-s.Done():
+		case <-time.After(time.Second):
+			t.Fatal("server process must terminate")
+		}
+	})
+}
+
+func TestMethodNormalizer(t *testing.T) {
+	aliases := map[string]string{"fetch": "get", "store": "put"}
+	normalize := func(serviceMethod string) string {
+		serviceMethod = strings.ToLower(serviceMethod)
+		if canonical, ok := aliases[serviceMethod]; ok {
+			return canonical
+		}
+		return serviceMethod
+	}
+
+	t.Run("should route alias to handler of canonical method", func(t *testing.T) {
+		// arrange
+		s := NewLazyStoreServer(WithMethodNormalizer(normalize))
+		defer s.Close()
+
+		// act
+		err := s.Call("store", KeyValuePair{"one", 1}, nil)
+		var v, v2 int
+		err2 := s.Call("fetch", "one", &v)
+		err3 := s.Call("get", "one", &v2)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, err2)
+		assert.Nil(t, err3)
+		assert.Equal(t, 1, v)
+		assert.Equal(t, 1, v2)
+	})
+
+	t.Run("should match method names case-insensitively", func(t *testing.T) {
+		// arrange
+		s := NewLazyStoreServer(WithMethodNormalizer(normalize), WithJournal(1))
+		defer s.Close()
+
+		// act
+		err := s.Call("PUT", KeyValuePair{"one", 1}, nil)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "put", s.RecentRequests()[0].ServiceMethod)
+	})
+}
+
+func TestCloseAll(t *testing.T) {
+	t.Run("should close all servers and join real failures", func(t *testing.T) {
+		// arrange
+		echo := NewEchoServer(0)
+		closed := NewEchoServer(0)
+		closed.Close()
+		buffered := Listen(func(genserv GenServer) *BufferedServer {
+			return &BufferedServer{GenServer: genserv}
+		})
+		buffered.Call("write", 1, nil)
+		defer func() {
+			buffered.Call("flush", nil, nil)
+			buffered.Close()
+		}()
+
+		// act
+		err := CloseAll(echo, closed, buffered)
+		<-echo.Done()
+
+		// assert
+		assert.ErrorIs(t, err, ErrUnflushed)
+		assert.Equal(t, ErrUnflushed.Error(), err.Error())
+	})
+
+	t.Run("should return nil if all servers are closed", func(t *testing.T) {
+		// arrange
+		one := NewEchoServer(0)
+		two := NewEchoServer(0)
+		two.Close()
+
+		// act
+		err := CloseAll(one, two)
+
+		// assert
+		assert.Nil(t, err)
+	})
+}
+
+func TestFallback(t *testing.T) {
+	t.Run("should route unknown methods to fallback", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *FallbackServer {
+			return &FallbackServer{GenServer: genserv}
+		}, WithKnownMethods([]string{"echo"}))
+		defer s.Close()
+
+		// act
+		var reply string
+		err1 := s.Call("echo", "foo", &reply)
+		err2 := s.Call("ping", "foo", nil)
+
+		// assert
+		assert.Nil(t, err1)
+		assert.Equal(t, "foo", reply)
+		assert.ErrorIs(t, err2, ErrUnknownMethod)
+		assert.EqualError(t, err2, "ping: unknown method")
+	})
+
+	t.Run("should return unknown method error if behaviour has no fallback", func(t *testing.T) {
+		// arrange
+		genserv := newGenServer(0, 0, WithKnownMethods([]string{"echo"}))
+		s := &EchoServer{GenServer: genserv}
+		go genserv.Listen(s)
+		defer s.Close()
+
+		// act
+		err := s.Call("ping", "foo", nil)
+
+		// assert
+		assert.ErrorIs(t, err, ErrUnknownMethod)
+	})
+}
+
+func TestInFlight(t *testing.T) {
+	t.Run("should count request being handled but not those in the mailbox", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv, delay: 200 * time.Millisecond}
+		})
+		defer s.Close()
+		idle := s.InFlight()
+
+		// act
+		calls := []*rpc.Call{s.Cast("echo", 1, nil, nil), s.Cast("echo", 2, nil, nil)}
+		time.Sleep(50 * time.Millisecond)
+		busy := s.InFlight()
+		queued := s.Stats().QueueLen
+		for _, call := range calls {
+			<-call.Done
+		}
+
+		// assert
+		assert.Equal(t, 0, idle)
+		assert.Equal(t, 1, busy)
+		assert.Equal(t, 1, queued)
+		assert.Equal(t, 0, s.InFlight())
+	})
+}
+
+func TestWaitIdle(t *testing.T) {
+	t.Run("should wait until queued requests are handled", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv, delay: 10 * time.Millisecond}
+		})
+		defer s.Close()
+		for i := 0; i < 5; i++ {
+			s.Cast("echo", i, nil, nil)
+		}
+
+		// act
+		err := s.WaitIdle(time.Second)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(5), s.Stats().Handled)
+	})
+
+	t.Run("should return error if server process is still busy", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(200 * time.Millisecond)
+		defer s.Close()
+		s.Cast("echo", nil, nil, nil)
+
+		// act
+		err := s.WaitIdle(20 * time.Millisecond)
+
+		// assert
+		assert.ErrorIs(t, err, ErrNotIdle)
+	})
+
+	t.Run("should measure timeout by clock of server process", func(t *testing.T) {
+		// arrange
+		clock := NewFakeClock()
+		s := NewEchoServer(200*time.Millisecond, WithClock(clock))
+		defer s.Close()
+		s.Cast("echo", nil, nil, nil)
+
+		// act
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.WaitIdle(time.Hour)
+		}()
+		time.Sleep(20 * time.Millisecond) // the deadline is set
+		clock.Advance(2 * time.Hour)
+
+		// assert
+		assert.ErrorIs(t, <-errs, ErrNotIdle)
+	})
+
+	t.Run("should return once idle even if clock of server process stands still", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(20*time.Millisecond, WithClock(NewFakeClock()))
+		defer s.Close()
+		s.Cast("echo", nil, nil, nil)
+
+		// act
+		err := s.WaitIdle(time.Hour)
+
+		// assert
+		assert.Nil(t, err)
+	})
+}
+
+func TestListenContext(t *testing.T) {
+	t.Run("should shut down gracefully once context is cancelled", func(t *testing.T) {
+		// arrange
+		genserv := NewGenServer()
+		s := &EchoServer{GenServer: genserv, delay: 100 * time.Millisecond}
+		ctx, cancel := context.WithCancel(context.Background())
+		listened := make(chan error, 1)
+		go func() {
+			listened <- genserv.ListenContext(ctx, s)
+		}()
+		var first int
+		inFlight := s.Cast("echo", 1, &first, nil)
+		time.Sleep(20 * time.Millisecond) // the first request is being handled
+
+		// act
+		cancel()
+		time.Sleep(20 * time.Millisecond)
+		err := s.Call("echo", 2, nil)
+		<-inFlight.Done
+		err2 := <-listened
+		<-s.Done()
+		err3 := s.Call("echo", 3, nil)
+
+		// assert
+		assert.ErrorIs(t, err, ErrServerClosing)
+		assert.Nil(t, inFlight.Error)
+		assert.Equal(t, 1, first)
+		assert.ErrorIs(t, err2, context.Canceled)
+		assert.ErrorIs(t, err3, rpc.ErrShutdown)
+	})
+
+	t.Run("should shut down paused server process once context is cancelled", func(t *testing.T) {
+		// arrange
+		genserv := NewGenServer()
+		s := &EchoServer{GenServer: genserv}
+		ctx, cancel := context.WithCancel(context.Background())
+		listened := make(chan error, 1)
+		go func() {
+			listened <- genserv.ListenContext(ctx, s)
+		}()
+		s.Call("echo", 1, nil)
+		s.Pause()
+		var queued int
+		call := s.Cast("echo", 2, &queued, nil)
+
+		// act
+		cancel()
+
+		// assert
+		select {
+		case err := <-listened:
+			assert.ErrorIs(t, err, context.Canceled)
+		case <-time.After(time.Second):
+			t.Fatal("server process must shut down")
+		}
+		<-call.Done
+		assert.Nil(t, call.Error)
+		assert.Equal(t, 2, queued)
+	})
+
+	t.Run("should return nil if server process is closed", func(t *testing.T) {
+		// arrange
+		genserv := NewGenServer()
+		s := &EchoServer{GenServer: genserv}
+		listened := make(chan error, 1)
+		go func() {
+			listened <- genserv.ListenContext(context.Background(), s)
+		}()
+		s.Call("echo", 1, nil)
+
+		// act
+		s.Close()
+		err := <-listened
+
+		// assert
+		assert.Nil(t, err)
+	})
+}
+
+func TestMaxRequests(t *testing.T) {
+	t.Run("should stop after handling the last allowed request", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0, WithMaxRequests(5), WithEvents(16, BlockOnEvents))
+
+		// act
+		var errs []error
+		for i := 0; i < 5; i++ {
+			errs = append(errs, s.Call("echo", i, nil))
+		}
+		var last Event
+		for e := range s.Events() {
+			last = e
+		}
+		<-s.Done()
+		err := s.Call("echo", 5, nil)
+
+		// assert
+		assert.Equal(t, make([]error, 5), errs)
+		assert.Equal(t, uint64(5), s.Stats().Handled)
+		assert.Equal(t, EventTerminated, last.Kind)
+		assert.ErrorIs(t, last.Reason, ErrMaxRequests)
+		assert.Error(t, err)
+	})
+
+	t.Run("should let owner recycle server process", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0, WithMaxRequests(2))
+		recycled := 0
+
+		// act
+		for i := 0; i < 5; i++ {
+			if s.Stats().Handled == 2 {
+				<-s.Done()
+				s = NewEchoServer(0, WithMaxRequests(2))
+				recycled++
+			}
+			assert.Nil(t, s.Call("echo", i, nil))
+		}
+		defer s.Close()
+
+		// assert
+		assert.Equal(t, 2, recycled)
+		assert.Equal(t, uint64(1), s.Stats().Handled)
+	})
+}
+
+func TestBusyIdleHooks(t *testing.T) {
+	t.Run("should fire once per transition between idle and busy", func(t *testing.T) {
+		// arrange
+		edges := make(chan string, 10)
+		s := Build(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv, delay: 5 * time.Millisecond}
+		}, WithOnBusy(func() { edges <- "busy" }), WithOnIdle(func() { edges <- "idle" }))
+		defer s.Close()
+		for i := 0; i < 5; i++ {
+			s.Cast("echo", i, nil, nil)
+		}
+
+		// act
+		s.Start()
+		first := []string{<-edges, <-edges}
+		s.Snapshot(func(Behaviour) {})
+		s.Call("echo", 1, nil)
+		second := []string{<-edges, <-edges}
+		time.Sleep(20 * time.Millisecond)
+
+		// assert
+		assert.Equal(t, []string{"busy", "idle"}, first)
+		assert.Equal(t, []string{"busy", "idle"}, second)
+		assert.Empty(t, edges)
+	})
+}
+
+func TestContext(t *testing.T) {
+	t.Run("should let handler read server-scoped value", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *GreetingServer {
+			return &GreetingServer{GenServer: genserv}
+		}, WithServerValue(greetingKey{}, "hello"))
+		defer s.Close()
+
+		// act
+		var reply string
+		err := s.Call("greet", "world", &reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "hello, world", reply)
+	})
+
+	t.Run("should return context without values by default", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+
+		// act
+		ctx := s.Context()
+
+		// assert
+		assert.NotNil(t, ctx)
+		assert.Nil(t, ctx.Value(greetingKey{}))
+	})
+}
+
+func TestBulkhead(t *testing.T) {
+	t.Run("should reject call beyond the limit of concurrent calls", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(200*time.Millisecond, WithBulkhead(2))
+		defer s.Close()
+
+		// act
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() { errs <- s.Call("echo", nil, nil) }()
+		}
+		time.Sleep(50 * time.Millisecond) // both calls are in flight
+		err := s.Call("echo", nil, nil)
+
+		// assert
+		assert.ErrorIs(t, err, ErrBulkheadFull)
+		assert.Nil(t, <-errs)
+		assert.Nil(t, <-errs)
+		assert.Nil(t, s.Call("echo", nil, nil))
+	})
+}
+
+func TestQuiesce(t *testing.T) {
+	t.Run("should reject new requests and keep handling enqueued ones", func(t *testing.T) {
+		// arrange
+		s := Build(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		})
+		defer s.Close()
+		var reply string
+		enqueued := s.Cast("echo", "foo", &reply, nil)
+
+		// act
+		s.Quiesce()
+		rejected := s.Cast("echo", "bar", nil, nil)
+		s.Start()
+		<-enqueued.Done
+		<-rejected.Done
+
+		// assert
+		assert.Nil(t, enqueued.Error)
+		assert.Equal(t, "foo", reply)
+		assert.ErrorIs(t, rejected.Error, ErrServerClosing)
+	})
+
+	t.Run("should stay alive for inspection", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+
+		// act
+		s.Quiesce()
+		err := s.Snapshot(func(Behaviour) {})
+
+		// assert
+		assert.Nil(t, err)
+		select {
+		case <-s.Done():
+			t.Fatal("server process is terminated")
+		default:
+		}
+	})
+
+	t.Run("should accept requests after resume", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+		s.Quiesce()
+
+		// act
+		err1 := s.Call("echo", "foo", nil)
+		s.Resume()
+		var reply string
+		err2 := s.Call("echo", "bar", &reply)
+
+		// assert
+		assert.ErrorIs(t, err1, ErrServerClosing)
+		assert.Nil(t, err2)
+		assert.Equal(t, "bar", reply)
+	})
+}
+
+func TestAbandonedCalls(t *testing.T) {
+	t.Run("should drain responses of abandoned calls", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv, delay: 5 * time.Millisecond}
+		})
+		defer s.Close()
+
+		// act
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.CallTimeout("echo", i, nil, time.Millisecond)
+			}()
+		}
+		wg.Wait()
+		abandoned := s.AbandonedCalls()
+		s.WaitIdle(time.Second)
+		var reply int
+		err := s.Call("echo", 1, &reply)
+
+		// assert
+		assert.Positive(t, abandoned)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, reply)
+		assert.Equal(t, 0, s.AbandonedCalls())
+	})
+
+	t.Run("should not accumulate casts whose done channels are never read", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+		var before, after runtime.MemStats
+
+		// act
+		for i := 0; i < 1000; i++ {
+			s.Cast("echo", i, nil, make(chan *rpc.Call, 1))
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		for i := 0; i < 10000; i++ {
+			s.Cast("echo", i, nil, make(chan *rpc.Call, 1))
+		}
+		s.WaitIdle(time.Second)
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		var reply int
+		err := s.Call("echo", 1, &reply)
 
 		// assert
-		assert.ErrorIs(t, call1.Error, rpc.ErrShutdown)
-		assert.NotNil(t, call2.Error)
-		assert.Contains(t, call2.Error.Error(), "send on closed channel")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, reply)
+		assert.Equal(t, 0, s.AbandonedCalls())
+		assert.Less(t, int64(after.HeapInuse)-int64(before.HeapInuse), int64(1<<20))
+	})
+}
+
+func TestUptime(t *testing.T) {
+	t.Run("should grow while server process is running", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+		s.Call("echo", nil, nil) // the server process has started
+
+		// act
+		first := s.Uptime()
+		time.Sleep(20 * time.Millisecond)
+		second := s.Uptime()
+
+		// assert
+		assert.Less(t, first, 20*time.Millisecond)
+		assert.GreaterOrEqual(t, second-first, 20*time.Millisecond)
+	})
+
+	t.Run("should be zero before start", func(t *testing.T) {
+		// arrange
+		s := Build(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		})
+		defer s.Close()
+
+		// act
+		uptime := s.Uptime()
+
+		// assert
+		assert.Zero(t, uptime)
+	})
+}
+
+func TestPause(t *testing.T) {
+	t.Run("should hold requests in mailbox until unpaused", func(t *testing.T) {
+		// arrange
+		s := NewLazyStoreServer()
+		defer s.Close()
+		s.Call("put", KeyValuePair{"zero", 0}, nil)
+
+		// act + assert
+		s.Pause()
+		done := make(chan *rpc.Call, 3)
+		for i := 1; i <= 3; i++ {
+			s.Cast("put", KeyValuePair{strconv.Itoa(i), i}, nil, done)
+		}
+		time.Sleep(50 * time.Millisecond)
+		assert.Len(t, s.data, 1) // no handler runs while paused, so the state can be read directly
+		assert.Empty(t, done)
+
+		s.Unpause()
+		for i := 0; i < 3; i++ {
+			assert.Nil(t, (<-done).Error)
+		}
+		var size int
+		s.Snapshot(func(Behaviour) { size = len(s.data) })
+		assert.Equal(t, 4, size)
+	})
+
+	t.Run("should lift pause and stopped intake independently", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+		s.Pause()
+		queued := s.Cast("echo", nil, nil, nil)
+		s.Quiesce()
+
+		// act
+		s.Unpause()
+		<-queued.Done
+		rejected := s.Call("echo", nil, nil)
+		s.Pause()
+		s.Resume()
+		held := s.Cast("echo", nil, nil, nil)
+		time.Sleep(20 * time.Millisecond)
+		handledWhilePaused := s.Stats().Handled
+		s.Unpause()
+		<-held.Done
+
+		// assert
+		assert.Nil(t, queued.Error)
+		assert.ErrorIs(t, rejected, ErrServerClosing)
+		assert.Equal(t, uint64(1), handledWhilePaused)
+		assert.Nil(t, held.Error)
+	})
+
+	t.Run("should wait for request being handled", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv, delay: 50 * time.Millisecond}
+		})
+		defer s.Close()
+		s.Cast("echo", nil, nil, nil)
+		time.Sleep(10 * time.Millisecond) // the handler has started
+
+		// act
+		s.Pause()
+		handled := s.Stats().Handled
+		s.Unpause()
+
+		// assert
+		assert.Equal(t, uint64(1), handled)
+	})
+
+	t.Run("should close paused server process", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		s.Pause()
+		call := s.Cast("echo", nil, nil, nil)
+
+		// act
+		s.Close()
+		<-call.Done
+
+		// assert
+		assert.ErrorIs(t, call.Error, rpc.ErrShutdown)
+	})
+
+	t.Run("should consult behaviour of paused server process on close", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *BufferedServer {
+			return &BufferedServer{GenServer: genserv}
+		})
+		s.Call("write", 1, nil)
+		s.Pause()
+
+		// act
+		err := s.Close()
+		s.pending = nil // no handler runs while paused
+		err2 := s.Close()
+		<-s.Done()
+
+		// assert
+		assert.ErrorIs(t, err, ErrUnflushed)
+		assert.Nil(t, err2)
+	})
+}
+
+func TestStats(t *testing.T) {
+	t.Run("should count handled and failed requests", func(t *testing.T) {
+		// arrange
+		s := NewPingServer()
+		defer s.Close()
+
+		// act
+		s.Call("echo", "foo", nil)
+		s.Call("ping", nil, nil)
+		s.Call("echo", "bar", nil)
+		s.Call("ping", nil, nil)
+		s.Call("echo", "baz", nil)
+		stats := s.Stats()
+
+		// assert
+		assert.Equal(t, uint64(5), stats.Handled)
+		assert.Equal(t, uint64(2), stats.Errors)
+		assert.Equal(t, 0, stats.QueueLen)
+		assert.Greater(t, stats.Uptime, time.Duration(0))
+	})
+
+	t.Run("should report pending requests of server that hasn't started", func(t *testing.T) {
+		// arrange
+		s := Build(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		})
+		defer s.Close()
+
+		// act
+		s.Cast("echo", "foo", nil, nil)
+		s.Cast("echo", "bar", nil, nil)
+		stats := s.Stats()
+
+		// assert
+		assert.Equal(t, ServerStats{QueueLen: 2}, stats)
+	})
+}
+
+func TestListenE(t *testing.T) {
+	t.Run("should return behaviour when init succeeds", func(t *testing.T) {
+		// arrange + act
+		s, err := ListenE(func(genserv GenServer) *InitServer {
+			return &InitServer{GenServer: genserv}
+		})
+		defer s.Close()
+
+		// assert
+		assert.Nil(t, err)
+		assert.True(t, s.initialized)
+	})
+
+	t.Run("should return init error and close server", func(t *testing.T) {
+		// arrange
+		expectedErr := errors.New("failed to load state")
+
+		// act
+		s, err := ListenE(func(genserv GenServer) *InitServer {
+			return &InitServer{GenServer: genserv, err: expectedErr}
+		})
+
+		// assert
+		assert.ErrorIs(t, err, expectedErr)
+		<-s.Done()
+		assert.ErrorIs(t, s.Call("", nil, nil), rpc.ErrShutdown)
+	})
+}
+
+func TestInitPolicy(t *testing.T) {
+	t.Run("should hold requests until init completes", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *InitServer {
+			return &InitServer{GenServer: genserv, delay: 100 * time.Millisecond}
+		})
+		defer s.Close()
+
+		// act
+		var reply string
+		err := s.Call("echo", "foo", &reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "foo", reply)
+	})
+
+	t.Run("should reject requests until init completes", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *InitServer {
+			return &InitServer{GenServer: genserv, delay: 100 * time.Millisecond}
+		}, WithInitPolicy(InitReject))
+		defer s.Close()
+
+		// act
+		err := s.Call("echo", "foo", nil)
+		<-s.GenServer.(*genServer).ready
+		err2 := s.Call("echo", "foo", nil)
+
+		// assert
+		assert.ErrorIs(t, err, ErrNotReady)
+		assert.Nil(t, err2)
+	})
+}
+
+func TestPostpone(t *testing.T) {
+	t.Run("should handle postponed request after state has changed", func(t *testing.T) {
+		// arrange
+		s := NewLazyStoreServer()
+		defer s.Close()
+
+		// act
+		var reply int
+		get := s.Cast("get", "one", &reply, nil)
+		err := s.Call("put", KeyValuePair{"one", 1}, nil)
+		<-get.Done
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, get.Error)
+		assert.Equal(t, 1, reply)
+	})
+
+	t.Run("should fail request postponed more times than allowed", func(t *testing.T) {
+		// arrange
+		s := NewLazyStoreServer(WithPostponeLimit(1))
+		defer s.Close()
+
+		// act
+		get := s.Cast("get", "one", nil, nil)
+		err := s.Call("put", KeyValuePair{"two", 2}, nil)
+		<-get.Done
+
+		// assert
+		assert.Nil(t, err)
+		assert.ErrorIs(t, get.Error, ErrPostponeLimit)
 	})
 }
 
+var _ Metrics = (*metricsRecorder)(nil)
+
+type metricsRecorder struct {
+	mu            sync.Mutex
+	enqueueBlocks []time.Duration
+}
+
+func (m *metricsRecorder) ObserveEnqueueBlock(dur time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enqueueBlocks = append(m.enqueueBlocks, dur)
+}
+
+func (m *metricsRecorder) MaxEnqueueBlock() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var max time.Duration
+	for _, dur := range m.enqueueBlocks {
+		if dur > max {
+			max = dur
+		}
+	}
+	return max
+}
+
 var _ Behaviour = (*EchoServer)(nil)
 
-func NewEchoServer(delay time.Duration) *EchoServer {
-	genserv := newGenServer(0, 0)
+func NewEchoServer(delay time.Duration, opts ...Option) *EchoServer {
+	genserv := newGenServer(0, 0, opts...)
 	s := &EchoServer{GenServer: genserv, delay: delay}
 	go genserv.Listen(s)
 	return s
@@ -65,10 +1844,10 @@ func (s *EchoServer) Handle(serviceMethod string, _ uint64, body any) (any, erro
 
 var _ Behaviour = (*PanicServer)(nil)
 
-func NewPanicServer(err error) *PanicServer {
+func NewPanicServer(err error, opts ...Option) *PanicServer {
 	return Listen(func(genserv GenServer) *PanicServer {
 		return &PanicServer{GenServer: genserv, err: err}
-	})
+	}, opts...)
 }
 
 type PanicServer struct {
@@ -79,3 +1858,316 @@ type PanicServer struct {
 func (s *PanicServer) Handle(_ string, _ uint64, _ any) (any, error) {
 	panic(s.err)
 }
+
+var _ ContextBehaviour = (*ChainServer)(nil)
+
+// Forwards requests to the next server process with the context of the caller
+type ChainServer struct {
+	GenServer
+	next GenServer
+}
+
+func (s *ChainServer) Handle(_ string, _ uint64, _ any) (any, error) {
+	return nil, errors.New("must be unreachable")
+}
+
+func (s *ChainServer) HandleContext(ctx context.Context, serviceMethod string, _ uint64, body any) (any, error) {
+	return nil, s.next.CallContext(ctx, serviceMethod, body, nil)
+}
+
+var _ FallbackBehaviour = (*FallbackServer)(nil)
+
+type FallbackServer struct {
+	GenServer
+}
+
+func (s *FallbackServer) Handle(_ string, _ uint64, body any) (any, error) {
+	return body, nil
+}
+
+func (s *FallbackServer) HandleUnknown(serviceMethod string, _ uint64, _ any) (any, error) {
+	return nil, fmt.Errorf("%s: %w", serviceMethod, ErrUnknownMethod)
+}
+
+type greetingKey struct{}
+
+// Greets with the greeting injected via the context of the server process
+type GreetingServer struct {
+	GenServer
+}
+
+func (s *GreetingServer) Handle(_ string, _ uint64, body any) (any, error) {
+	greeting := s.Context().Value(greetingKey{}).(string)
+	return greeting + ", " + body.(string), nil
+}
+
+// Returns the same error for every request
+type FailingServer struct {
+	GenServer
+	err error
+}
+
+func (s *FailingServer) Handle(_ string, _ uint64, _ any) (any, error) {
+	return nil, s.err
+}
+
+type ValidationError struct {
+	Field string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid " + e.Field
+}
+
+func BenchmarkGenServer(b *testing.B) {
+	b.Run("call", func(b *testing.B) {
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		})
+		defer s.Close()
+
+		var reply int
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			s.Call("", i, &reply)
+		}
+	})
+
+	b.Run("call into reused reply", func(b *testing.B) {
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		})
+		defer s.Close()
+
+		args := any(42)
+		var reply int
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			s.CallInto("", args, &reply)
+		}
+	})
+
+	b.Run("call into fresh reply", func(b *testing.B) {
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		})
+		defer s.Close()
+
+		args := any(42)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			s.CallInto("", args, new(int))
+		}
+	})
+
+	b.Run("cast", func(b *testing.B) {
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		})
+		defer s.Close()
+
+		done := make(chan *rpc.Call, 1)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			<-s.Cast("", i, nil, done).Done
+		}
+	})
+
+	b.Run("pipelined responses", func(b *testing.B) {
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		})
+		defer s.Close()
+
+		const batch = 1024
+		done := make(chan *rpc.Call, batch)
+		b.ReportAllocs()
+		b.ResetTimer()
+		start := time.Now()
+		for i := 0; i < b.N; i += batch {
+			n := min(batch, b.N-i)
+			for j := 0; j < n; j++ {
+				s.Cast("", j, nil, done)
+			}
+			for j := 0; j < n; j++ {
+				<-done
+			}
+		}
+		b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "responses/s")
+	})
+}
+
+var _ Behaviour = (*LazyStoreServer)(nil)
+
+func NewLazyStoreServer(opts ...Option) *LazyStoreServer {
+	return Listen(func(genserv GenServer) *LazyStoreServer {
+		return &LazyStoreServer{GenServer: genserv, data: make(map[string]int)}
+	}, opts...)
+}
+
+type KeyValuePair struct {
+	Key   string
+	Value int
+}
+
+// Postpones `get` of a missing key until it is `put`
+type LazyStoreServer struct {
+	GenServer
+	data map[string]int
+}
+
+func (s *LazyStoreServer) Handle(serviceMethod string, _ uint64, body any) (any, error) {
+	switch serviceMethod {
+	case "get":
+		v, ok := s.data[body.(string)]
+		if !ok {
+			return nil, Postpone
+		}
+		return v, nil
+	case "put":
+		kvp := body.(KeyValuePair)
+		s.data[kvp.Key] = kvp.Value
+		s.Publish("kv.put", kvp)
+		return nil, nil
+	}
+	return nil, errors.New("unknown method")
+}
+
+var _ InitBehaviour = (*InitServer)(nil)
+
+type InitServer struct {
+	GenServer
+	err         error
+	delay       time.Duration
+	initialized bool
+}
+
+func (s *InitServer) Init() error {
+	time.Sleep(s.delay)
+	s.initialized = s.err == nil
+	return s.err
+}
+
+func (s *InitServer) Handle(_ string, _ uint64, body any) (any, error) {
+	return body, nil
+}
+
+var _ TimedBehaviour = (*QueueWaitServer)(nil)
+
+// Replies with the time the request spent in the mailbox
+type QueueWaitServer struct {
+	GenServer
+}
+
+func (s *QueueWaitServer) Handle(_ string, _ uint64, _ any) (any, error) {
+	return nil, errors.New("must be unreachable")
+}
+
+func (s *QueueWaitServer) HandleTimed(_ string, _ uint64, _ any, enqueuedAt time.Time) (any, error) {
+	return time.Since(enqueuedAt), nil
+}
+
+var _ CancelableBehaviour = (*CancelableServer)(nil)
+
+// Works for an hour unless the caller stops waiting
+type CancelableServer struct {
+	GenServer
+	cancelled bool
+}
+
+func (s *CancelableServer) Handle(_ string, _ uint64, _ any) (any, error) {
+	return nil, errors.New("must be unreachable")
+}
+
+func (s *CancelableServer) HandleCancelable(_ string, _ uint64, _ any, cancel <-chan struct{}) (any, error) {
+	select {
+	case <-time.After(time.Hour):
+		return nil, nil
+	case <-cancel:
+		s.cancelled = true
+		return nil, errors.New("cancelled")
+	}
+}
+
+// Mutates the slice it receives and keeps it
+type MutatingServer struct {
+	GenServer
+	last []int
+}
+
+func (s *MutatingServer) Handle(serviceMethod string, _ uint64, body any) (any, error) {
+	if serviceMethod == "last" {
+		return s.last, nil
+	}
+	s.last = body.([]int)
+	s.last[0] = -1
+	return s.last, nil
+}
+
+// Calls itself from its own handler
+type ReentrantServer struct {
+	GenServer
+}
+
+func (s *ReentrantServer) Handle(serviceMethod string, _ uint64, body any) (any, error) {
+	if serviceMethod == "reenter" {
+		return nil, s.Call("echo", body, nil)
+	}
+	return body, nil
+}
+
+var ErrUnflushed = errors.New("unflushed writes")
+
+var _ PrepareShutdownBehaviour = (*BufferedServer)(nil)
+
+// Buffers writes in memory and refuses to be closed until they are flushed
+type BufferedServer struct {
+	GenServer
+	pending []any
+}
+
+func (s *BufferedServer) Handle(serviceMethod string, _ uint64, body any) (any, error) {
+	switch serviceMethod {
+	case "write":
+		s.pending = append(s.pending, body)
+	case "flush":
+		s.pending = nil
+	case "crash":
+		panic("crash")
+	}
+	return nil, nil
+}
+
+func (s *BufferedServer) CanShutdown() error {
+	if len(s.pending) > 0 {
+		return ErrUnflushed
+	}
+	return nil
+}
+
+var _ ModeBehaviour = (*ModeServer)(nil)
+
+// Deletes keys only when the caller waits for the confirmation
+type ModeServer struct {
+	GenServer
+	deleted []string
+}
+
+func (s *ModeServer) Handle(serviceMethod string, seq uint64, body any) (any, error) {
+	return s.HandleMode(ModeCall, serviceMethod, seq, body)
+}
+
+func (s *ModeServer) HandleMode(mode Mode, serviceMethod string, _ uint64, body any) (any, error) {
+	if serviceMethod != "delete" {
+		return nil, fmt.Errorf("unknown method %q", serviceMethod)
+	}
+	if mode == ModeCast {
+		return nil, ErrMustUseCall
+	}
+	s.deleted = append(s.deleted, body.(string))
+	return body, nil
+}
@@ -0,0 +1,40 @@
+// Package genservertest provides utilities for testing behaviours of server processes
+package genservertest
+
+import (
+	"fmt"
+
+	"github.com/mapogolions/genserver"
+)
+
+// Driver invokes `Behaviour.Handle` directly on the calling goroutine, bypassing the mailbox
+// and `rpc.Client`. Use it for fast unit tests of handler logic
+type Driver struct {
+	behaviour genserver.Behaviour
+	seq       uint64
+}
+
+func NewDriver(behaviour genserver.Behaviour) *Driver {
+	return &Driver{behaviour: behaviour}
+}
+
+// Calls `Handle` with the given arguments. A panic is returned as an error, as the server process does
+func (d *Driver) Handle(serviceMethod string, seq uint64, body any) (v any, err error) {
+	defer func() {
+		if info := recover(); info != nil {
+			if e, ok := info.(error); ok {
+				err = e
+				return
+			}
+			err = fmt.Errorf("%v", info)
+		}
+	}()
+	return d.behaviour.Handle(serviceMethod, seq, body)
+}
+
+// Same as `Handle` but assigns sequence numbers the way the server process does, starting from 0
+func (d *Driver) Send(serviceMethod string, body any) (any, error) {
+	seq := d.seq
+	d.seq++
+	return d.Handle(serviceMethod, seq, body)
+}
@@ -0,0 +1,54 @@
+package genserver
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+)
+
+var goroutinePrefix = []byte("goroutine ")
+
+// Returns the id of the current goroutine parsed from the header of its stack trace, e.g. "goroutine 42 [running]:"
+func goid() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, goroutinePrefix)
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+// Runs the behaviour, so its goroutine can be told apart from others by the call stack, see `onHandler`
+//
+//go:noinline
+func handlerFrame(f func()) {
+	f()
+}
+
+// Return address of the call in `handlerFrame`, found on the call stack of every running handler
+var handlerPC = func() (pc uintptr) {
+	handlerFrame(func() {
+		var pcs [1]uintptr
+		runtime.Callers(2, pcs[:]) // skips `runtime.Callers` and this function
+		pc = pcs[0]
+	})
+	return pc
+}()
+
+// Reports whether the caller may be running inside a handler of some server process. It walks return addresses
+// without formatting the stack, so it rules out other goroutines much cheaper than `goid`
+func onHandler() bool {
+	var pcs [64]uintptr
+	n := runtime.Callers(3, pcs[:]) // skips `runtime.Callers`, this function and its caller
+	if n == len(pcs) {
+		return true // too deep to tell, the id of the goroutine decides
+	}
+	for _, pc := range pcs[:n] {
+		if pc == handlerPC {
+			return true
+		}
+	}
+	return false
+}
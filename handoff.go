@@ -0,0 +1,41 @@
+package genserver
+
+import "errors"
+
+var ErrHandoffNotSupported = errors.New("behaviour does not support handoff")
+
+// Optional contract of a behaviour whose state can be moved to another server process, see `Takeover`
+type HandoffBehaviour interface {
+	Behaviour
+	ExportState() any
+	ImportState(state any) error
+}
+
+// Exports the state of the primary and imports it into the standby. Both steps run on the respective
+// server processes, serialized with their requests. The primary keeps running, so stop sending
+// requests to it before the takeover, otherwise they are not reflected in the standby
+func Takeover(standby, primary GenServer) error {
+	var state any
+	var err error
+	snapshotErr := primary.Snapshot(func(b Behaviour) {
+		h, ok := b.(HandoffBehaviour)
+		if !ok {
+			err = ErrHandoffNotSupported
+			return
+		}
+		state = h.ExportState()
+	})
+	if err := errors.Join(snapshotErr, err); err != nil {
+		return err
+	}
+
+	snapshotErr = standby.Snapshot(func(b Behaviour) {
+		h, ok := b.(HandoffBehaviour)
+		if !ok {
+			err = ErrHandoffNotSupported
+			return
+		}
+		err = h.ImportState(state)
+	})
+	return errors.Join(snapshotErr, err)
+}
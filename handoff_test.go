@@ -0,0 +1,23 @@
+package genserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTakeover(t *testing.T) {
+	t.Run("should return error if behaviour does not support handoff", func(t *testing.T) {
+		// arrange
+		primary := NewEchoServer(0)
+		defer primary.Close()
+		standby := NewEchoServer(0)
+		defer standby.Close()
+
+		// act
+		err := Takeover(standby, primary)
+
+		// assert
+		assert.ErrorIs(t, err, ErrHandoffNotSupported)
+	})
+}
@@ -0,0 +1,69 @@
+package genserver
+
+import (
+	"fmt"
+	"time"
+)
+
+// Service method reserved for `GenServer.Ping`, it's answered by the server process itself
+const PingMethod = "__ping__"
+
+type Status int
+
+const (
+	StatusUp Status = iota
+	// The server process is serving requests, but not at its best
+	StatusDegraded
+	StatusDown
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusUp:
+		return "Up"
+	case StatusDegraded:
+		return "Degraded"
+	case StatusDown:
+		return "Down"
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// Result of `GenServer.Ping`
+type Health struct {
+	Status Status
+	// Round trip of the ping through the mailbox
+	Latency time.Duration
+}
+
+// Optional contract. If implemented, `Health` is called by the server process on `GenServer.Ping`,
+// serialized with requests. If it fails, `Ping` returns its error and reports `StatusDown`
+type HealthBehaviour interface {
+	Behaviour
+	Health() (Status, error)
+}
+
+// Internal request that is answered by the server process, see `GenServer.Ping`
+type ping struct{}
+
+func (c *genServerCodec) handlePing(behaviour Behaviour, req request) {
+	b, ok := behaviour.(HealthBehaviour)
+	if !ok {
+		c.respond(req, StatusUp, nil)
+		return
+	}
+	var status Status
+	var err error
+	tryCatch(func() { status, err = b.Health() }, &err)
+	c.respond(req, status, err)
+}
+
+func (s *genServer) Ping(timeout time.Duration) (Health, error) {
+	start := s.codec.options.clock.Now()
+	var status Status
+	err := s.CallTimeout(PingMethod, ping{}, &status, timeout)
+	if err != nil {
+		status = StatusDown
+	}
+	return Health{Status: status, Latency: s.codec.options.clock.Now().Sub(start)}, err
+}
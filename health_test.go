@@ -0,0 +1,89 @@
+package genserver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPing(t *testing.T) {
+	t.Run("should answer ping of any behaviour", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+
+		// act
+		health, err := s.Ping(time.Second)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, StatusUp, health.Status)
+		assert.Greater(t, health.Latency, time.Duration(0))
+	})
+
+	t.Run("should report health of behaviour", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *HealthServer {
+			return &HealthServer{GenServer: genserv, status: StatusDegraded}
+		})
+		defer s.Close()
+
+		// act
+		health, err := s.Ping(time.Second)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, StatusDegraded, health.Status)
+	})
+
+	t.Run("should report unhealthy behaviour as down", func(t *testing.T) {
+		// arrange
+		errNoConnection := errors.New("no connection to database")
+		s := Listen(func(genserv GenServer) *HealthServer {
+			return &HealthServer{GenServer: genserv, err: errNoConnection}
+		})
+		defer s.Close()
+
+		// act
+		health, err := s.Ping(time.Second)
+
+		// assert
+		assert.ErrorIs(t, err, errNoConnection)
+		assert.Equal(t, StatusDown, health.Status)
+	})
+
+	t.Run("should report busy server process as down", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv, delay: 200 * time.Millisecond}
+		})
+		defer s.Close()
+		s.Cast("echo", nil, nil, nil)
+
+		// act
+		health, err := s.Ping(20 * time.Millisecond)
+
+		// assert
+		assert.ErrorIs(t, err, ErrCallTimeout)
+		assert.Equal(t, StatusDown, health.Status)
+	})
+}
+
+var _ HealthBehaviour = (*HealthServer)(nil)
+
+// Reports the configured health, requests are not expected
+type HealthServer struct {
+	GenServer
+	status Status
+	err    error
+}
+
+func (s *HealthServer) Handle(serviceMethod string, _ uint64, _ any) (any, error) {
+	return nil, errors.New("unexpected request: " + serviceMethod)
+}
+
+func (s *HealthServer) Health() (Status, error) {
+	return s.status, s.err
+}
@@ -0,0 +1,119 @@
+package genserver
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestInterceptor(t *testing.T) {
+	errInjected := errors.New("injected fault")
+	newStore := func(genserv GenServer) *LazyStoreServer {
+		return &LazyStoreServer{GenServer: genserv, data: map[string]int{"foo": 1}}
+	}
+
+	t.Run("should inject delay and error for specific methods", func(t *testing.T) {
+		// arrange
+		s := Listen(newStore, WithRequestInterceptor(func(req *Request) error {
+			switch req.ServiceMethod {
+			case "get":
+				time.Sleep(50 * time.Millisecond)
+			case "put":
+				return errInjected
+			}
+			return nil
+		}))
+		defer s.Close()
+
+		// act
+		var value int
+		err := s.CallTimeout("get", "foo", &value, 10*time.Millisecond)
+		err2 := s.Call("put", KeyValuePair{Key: "foo", Value: 2}, nil)
+		err3 := s.Call("get", "foo", &value)
+
+		// assert
+		assert.ErrorIs(t, err, ErrCallTimeout)
+		assert.ErrorIs(t, err2, errInjected)
+		assert.Nil(t, err3)
+		assert.Equal(t, 1, value) // the put never reached the behaviour
+	})
+
+	t.Run("should hand modified request to behaviour", func(t *testing.T) {
+		// arrange
+		s := Listen(newStore, WithRequestInterceptor(func(req *Request) error {
+			if kvp, ok := req.Body.(KeyValuePair); ok {
+				req.Body = KeyValuePair{Key: kvp.Key, Value: -kvp.Value}
+			}
+			return nil
+		}))
+		defer s.Close()
+
+		// act
+		s.Call("put", KeyValuePair{Key: "bar", Value: 2}, nil)
+		var value int
+		err := s.Call("get", "bar", &value)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, -2, value)
+	})
+
+	t.Run("should drop request without reply", func(t *testing.T) {
+		// arrange
+		s := Listen(newStore, WithRequestInterceptor(func(req *Request) error {
+			if req.ServiceMethod == "put" {
+				return Drop
+			}
+			return nil
+		}))
+
+		// act
+		err := s.CallTimeout("put", KeyValuePair{Key: "foo", Value: 2}, nil, 20*time.Millisecond)
+		var value int
+		err2 := s.Call("get", "foo", &value)
+		dropped := s.Cast("put", KeyValuePair{Key: "foo", Value: 3}, nil, nil)
+		s.Close()
+		<-dropped.Done
+
+		// assert
+		assert.ErrorIs(t, err, ErrCallTimeout)
+		assert.Nil(t, err2)
+		assert.Equal(t, 1, value)
+		assert.Error(t, dropped.Error)
+	})
+
+	t.Run("should fail duplicates of dropped request and handle later ones", func(t *testing.T) {
+		// arrange
+		dropped := atomic.Bool{}
+		s := Build(newStore, WithSingleFlight(func(serviceMethod string, body any) (string, bool) {
+			return body.(string), serviceMethod == "get"
+		}), WithRequestInterceptor(func(req *Request) error {
+			if req.ServiceMethod == "get" && dropped.CompareAndSwap(false, true) {
+				return Drop
+			}
+			return nil
+		}))
+		defer s.Close()
+		leader := s.Cast("get", "foo", nil, nil)
+		follower := s.Cast("get", "foo", nil, nil) // joins the flight of the leader
+
+		// act
+		s.Start()
+		<-follower.Done
+		var value int
+		err := s.CallTimeout("get", "foo", &value, time.Second)
+
+		// assert
+		assert.ErrorIs(t, follower.Error, Drop)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, value)
+		select {
+		case <-leader.Done:
+			t.Fatal("dropped request got a reply")
+		default:
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package genserver
+
+import "reflect"
+
+// Deep copy of `v` used by `WithReplyIsolation`. Pointers, slices, maps, arrays, interfaces and exported
+// struct fields are copied recursively, values shared within `v` stay shared within the copy. References of
+// different types to the same address, e.g. to a struct and to its first field, are copied separately.
+// Unexported struct fields, channels and functions are copied as is
+func clone(v any) any {
+	if v == nil {
+		return nil
+	}
+	return cloneValue(reflect.ValueOf(v), make(map[reference]reflect.Value)).Interface()
+}
+
+// Pointer, slice or map identified by its address and type: a struct and its first field share the address
+type reference struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// `seen` maps the copied pointers, slices and maps to their copies, so cycles terminate
+func cloneValue(v reflect.Value, seen map[reference]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		ref := reference{v.Pointer(), v.Type()}
+		if c, ok := seen[ref]; ok {
+			return c
+		}
+		c := reflect.New(v.Type().Elem())
+		seen[ref] = c
+		c.Elem().Set(cloneValue(v.Elem(), seen))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		ref := reference{v.Pointer(), v.Type()}
+		if c, ok := seen[ref]; ok && c.Len() == v.Len() {
+			return c
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		seen[ref] = c
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(cloneValue(v.Index(i), seen))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		ref := reference{v.Pointer(), v.Type()}
+		if c, ok := seen[ref]; ok {
+			return c
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		seen[ref] = c
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(cloneValue(iter.Key(), seen), cloneValue(iter.Value(), seen))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(cloneValue(v.Index(i), seen))
+		}
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(cloneValue(v.Elem(), seen))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v) // unexported fields are copied as is
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(cloneValue(v.Field(i), seen))
+			}
+		}
+		return c
+	}
+	return v
+}
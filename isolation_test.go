@@ -0,0 +1,93 @@
+package genserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplyIsolation(t *testing.T) {
+	t.Run("should not let caller mutate internal slice of behaviour via reply", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *MutatingServer {
+			return &MutatingServer{GenServer: genserv}
+		}, WithReplyIsolation())
+		defer s.Close()
+		s.Call("mutate", []int{1, 2, 3}, nil)
+
+		// act
+		var reply []int
+		err := s.Call("last", nil, &reply)
+		reply[1] = -2
+		var last []int
+		s.Snapshot(func(Behaviour) { last = s.last })
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, []int{-1, 2, 3}, last)
+	})
+}
+
+func TestClone(t *testing.T) {
+	type node struct {
+		Values []int
+		Attrs  map[string]*int
+		Next   *node
+	}
+
+	t.Run("should copy nested references", func(t *testing.T) {
+		// arrange
+		one := 1
+		original := &node{Values: []int{1, 2}, Attrs: map[string]*int{"one": &one}, Next: &node{Values: []int{3}}}
+
+		// act
+		copied := clone(original).(*node)
+		copied.Values[0] = -1
+		*copied.Attrs["one"] = -1
+		copied.Next.Values[0] = -3
+
+		// assert
+		assert.Equal(t, []int{1, 2}, original.Values)
+		assert.Equal(t, 1, one)
+		assert.Equal(t, []int{3}, original.Next.Values)
+	})
+
+	t.Run("should preserve cycles", func(t *testing.T) {
+		// arrange
+		original := &node{Values: []int{1}}
+		original.Next = original
+
+		// act
+		copied := clone(original).(*node)
+
+		// assert
+		assert.NotSame(t, original, copied)
+		assert.Same(t, copied, copied.Next)
+	})
+
+	t.Run("should copy references of different types to the same address", func(t *testing.T) {
+		// arrange
+		type inner struct{ A, B int }
+		type pair struct {
+			P *inner
+			Q *int
+		}
+		s := inner{A: 1, B: 2}
+		original := pair{P: &s, Q: &s.A}
+
+		// act
+		copied := clone(original).(pair)
+		copied.P.A = -1
+		*copied.Q = -2
+
+		// assert
+		assert.Equal(t, inner{A: 1, B: 2}, s)
+		assert.Equal(t, 2, copied.P.B)
+	})
+
+	t.Run("should return values without references as is", func(t *testing.T) {
+		assert.Nil(t, clone(nil))
+		assert.Equal(t, 42, clone(42))
+		assert.Equal(t, "foo", clone("foo"))
+	})
+}
@@ -0,0 +1,53 @@
+package genserver
+
+import "sync"
+
+// Handled request recorded by `WithJournal`
+type RequestLog struct {
+	Seq           uint64
+	ServiceMethod string
+	// Error returned to the caller, nil on success
+	Err error
+}
+
+// Ring buffer of the last handled requests. Written by the listen loop, read by any goroutine
+type journal struct {
+	mu      sync.Mutex
+	entries []RequestLog
+	next    int
+	full    bool
+}
+
+// Returns nil if the journal is disabled
+func newJournal(capacity int) *journal {
+	if capacity <= 0 {
+		return nil
+	}
+	return &journal{entries: make([]RequestLog, capacity)}
+}
+
+func (j *journal) record(entry RequestLog) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries[j.next] = entry
+	j.next = (j.next + 1) % len(j.entries)
+	j.full = j.full || j.next == 0
+}
+
+// Returns a copy of the recorded entries, oldest first
+func (j *journal) recent() []RequestLog {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.full {
+		return append([]RequestLog(nil), j.entries[:j.next]...)
+	}
+	recent := make([]RequestLog, 0, len(j.entries))
+	recent = append(recent, j.entries[j.next:]...)
+	return append(recent, j.entries[:j.next]...)
+}
@@ -0,0 +1,69 @@
+package genserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournal(t *testing.T) {
+	t.Run("should record handled requests in order", func(t *testing.T) {
+		// arrange
+		s := NewPingServer(WithJournal(8))
+		defer s.Close()
+
+		// act
+		s.Call("echo", "foo", nil)
+		s.Call("ping", nil, nil)
+		s.Call("echo", "bar", nil)
+
+		// assert
+		assert.Equal(t, []RequestLog{
+			{Seq: 0, ServiceMethod: "echo"},
+			{Seq: 1, ServiceMethod: "ping", Err: ErrUnknownMethod},
+			{Seq: 2, ServiceMethod: "echo"},
+		}, s.RecentRequests())
+	})
+
+	t.Run("should keep only the last requests", func(t *testing.T) {
+		// arrange
+		s := NewPingServer(WithJournal(2))
+		defer s.Close()
+
+		// act
+		for i := 0; i < 5; i++ {
+			s.Call("echo", i, nil)
+		}
+
+		// assert
+		assert.Equal(t, []RequestLog{
+			{Seq: 3, ServiceMethod: "echo"},
+			{Seq: 4, ServiceMethod: "echo"},
+		}, s.RecentRequests())
+	})
+
+	t.Run("should be readable after server process terminated", func(t *testing.T) {
+		// arrange
+		s := NewPingServer(WithJournal(8))
+
+		// act
+		s.Call("ping", nil, nil)
+		s.Close()
+		<-s.Done()
+
+		// assert
+		assert.Equal(t, []RequestLog{{Seq: 0, ServiceMethod: "ping", Err: ErrUnknownMethod}}, s.RecentRequests())
+	})
+
+	t.Run("should return nil if journal is disabled", func(t *testing.T) {
+		// arrange
+		s := NewPingServer()
+		defer s.Close()
+
+		// act
+		s.Call("echo", "foo", nil)
+
+		// assert
+		assert.Nil(t, s.RecentRequests())
+	})
+}
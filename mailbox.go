@@ -0,0 +1,406 @@
+package genserver
+
+import (
+	"container/heap"
+	"net/rpc"
+	"sync"
+)
+
+// Mailbox of a server process. Filled by `rpc.Client` and drained by the listen loop
+type mailbox interface {
+	// Puts the request into the mailbox without blocking. Returns false if the mailbox is full
+	tryPut(req request) (bool, error)
+	// Puts the request into the mailbox, blocks while the mailbox is full
+	put(req request) error
+	// Blocks until a request is available. Returns false if the mailbox is closed and drained
+	get() (request, bool)
+	len() int
+	cap() int
+	close()
+}
+
+// Default FIFO mailbox backed by a buffered channel.
+// `close` waits for blocked senders to leave, so the channel is never closed under a send
+type chanMailbox struct {
+	mu      sync.RWMutex
+	ch      chan request
+	closing chan struct{}
+}
+
+func newChanMailbox(capacity uint) *chanMailbox {
+	return &chanMailbox{ch: make(chan request, capacity), closing: make(chan struct{})}
+}
+
+func (m *chanMailbox) tryPut(req request) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	select {
+	case <-m.closing:
+		return false, rpc.ErrShutdown
+	default:
+	}
+	select {
+	case m.ch <- req:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+func (m *chanMailbox) put(req request) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	select {
+	case <-m.closing:
+		return rpc.ErrShutdown
+	default:
+	}
+	select {
+	case m.ch <- req:
+		return nil
+	case <-m.closing:
+		return rpc.ErrShutdown
+	}
+}
+
+func (m *chanMailbox) get() (request, bool) {
+	req, ok := <-m.ch
+	return req, ok
+}
+
+func (m *chanMailbox) len() int {
+	return len(m.ch)
+}
+
+func (m *chanMailbox) cap() int {
+	return cap(m.ch)
+}
+
+func (m *chanMailbox) close() {
+	close(m.closing) // wakes up blocked senders
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	close(m.ch)
+}
+
+// Mailbox that buckets requests by key and takes them round-robin across buckets,
+// so a single busy key can't monopolize the server process. Requests with the same key stay FIFO
+type fairMailbox struct {
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	keyFn    func(Request) string
+	buckets  map[string][]request
+	keys     []string // round-robin order of non-empty buckets
+	size     int
+	capacity int
+	closed   bool
+}
+
+func newFairMailbox(capacity uint, keyFn func(Request) string) *fairMailbox {
+	m := &fairMailbox{
+		keyFn:    keyFn,
+		buckets:  make(map[string][]request),
+		capacity: max(int(capacity), 1),
+	}
+	m.notEmpty = sync.NewCond(&m.mu)
+	m.notFull = sync.NewCond(&m.mu)
+	return m
+}
+
+func (m *fairMailbox) tryPut(req request) (bool, error) {
+	key := m.keyFn(req.export())
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return false, rpc.ErrShutdown
+	}
+	if m.size >= m.capacity {
+		return false, nil
+	}
+	m.push(key, req)
+	return true, nil
+}
+
+func (m *fairMailbox) put(req request) error {
+	key := m.keyFn(req.export())
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for m.size >= m.capacity && !m.closed {
+		m.notFull.Wait()
+	}
+	if m.closed {
+		return rpc.ErrShutdown
+	}
+	m.push(key, req)
+	return nil
+}
+
+func (m *fairMailbox) push(key string, req request) {
+	bucket, ok := m.buckets[key]
+	if !ok || len(bucket) == 0 {
+		m.keys = append(m.keys, key)
+	}
+	m.buckets[key] = append(bucket, req)
+	m.size++
+	m.notEmpty.Signal()
+}
+
+func (m *fairMailbox) get() (request, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for m.size == 0 && !m.closed {
+		m.notEmpty.Wait()
+	}
+	if m.size == 0 {
+		return request{}, false
+	}
+	key := m.keys[0]
+	bucket := m.buckets[key]
+	req := bucket[0]
+	if len(bucket) == 1 {
+		delete(m.buckets, key)
+		m.keys = m.keys[1:]
+	} else {
+		m.buckets[key] = bucket[1:]
+		m.keys = append(m.keys[1:], key) // move the key to the back of the line
+	}
+	m.size--
+	m.notFull.Signal()
+	return req, true
+}
+
+func (m *fairMailbox) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.size
+}
+
+func (m *fairMailbox) cap() int {
+	return m.capacity
+}
+
+func (m *fairMailbox) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	m.notEmpty.Broadcast()
+	m.notFull.Broadcast()
+}
+
+// Mailbox that always yields the pending request with the highest priority, `Meta.Priority` unless
+// `WithMethodPriority` ranks its method. Ties are broken by arrival order
+type priorityMailbox struct {
+	mu       sync.Mutex
+	methods  map[string]int
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	queue    priorityQueue
+	arrivals uint64
+	capacity int
+	closed   bool
+}
+
+func newPriorityMailbox(capacity uint, methods map[string]int) *priorityMailbox {
+	m := &priorityMailbox{capacity: max(int(capacity), 1), methods: methods}
+	m.notEmpty = sync.NewCond(&m.mu)
+	m.notFull = sync.NewCond(&m.mu)
+	return m
+}
+
+func (m *priorityMailbox) tryPut(req request) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return false, rpc.ErrShutdown
+	}
+	if len(m.queue) >= m.capacity {
+		return false, nil
+	}
+	m.push(req)
+	return true, nil
+}
+
+func (m *priorityMailbox) put(req request) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for len(m.queue) >= m.capacity && !m.closed {
+		m.notFull.Wait()
+	}
+	if m.closed {
+		return rpc.ErrShutdown
+	}
+	m.push(req)
+	return nil
+}
+
+func (m *priorityMailbox) push(req request) {
+	priority, ok := m.methods[req.serviceMethod]
+	if !ok {
+		priority = req.meta.Priority
+	}
+	heap.Push(&m.queue, prioritized{request: req, priority: priority, arrival: m.arrivals})
+	m.arrivals++
+	m.notEmpty.Signal()
+}
+
+func (m *priorityMailbox) get() (request, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for len(m.queue) == 0 && !m.closed {
+		m.notEmpty.Wait()
+	}
+	if len(m.queue) == 0 {
+		return request{}, false
+	}
+	item := heap.Pop(&m.queue).(prioritized)
+	m.notFull.Signal()
+	return item.request, true
+}
+
+func (m *priorityMailbox) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.queue)
+}
+
+func (m *priorityMailbox) cap() int {
+	return m.capacity
+}
+
+func (m *priorityMailbox) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	m.notEmpty.Broadcast()
+	m.notFull.Broadcast()
+}
+
+type prioritized struct {
+	request
+	priority int
+	arrival  uint64
+}
+
+// Implements `heap.Interface`
+type priorityQueue []prioritized
+
+func (q priorityQueue) Len() int {
+	return len(q)
+}
+
+func (q priorityQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].arrival < q[j].arrival
+}
+
+func (q priorityQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *priorityQueue) Push(x any) {
+	*q = append(*q, x.(prioritized))
+}
+
+func (q *priorityQueue) Pop() any {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = prioritized{} // don't retain the request
+	*q = old[:n-1]
+	return item
+}
+
+// Mailbox with a bounded overflow queue of lower priority. Requests spill into the overflow queue
+// when the primary one is full, and are taken from it only when the primary queue is empty
+type overflowMailbox struct {
+	mu          sync.Mutex
+	notEmpty    *sync.Cond
+	notFull     *sync.Cond
+	primary     []request
+	overflow    []request
+	primaryCap  int
+	overflowCap int
+	closed      bool
+}
+
+func newOverflowMailbox(primaryCap uint, overflowCap uint) *overflowMailbox {
+	m := &overflowMailbox{primaryCap: max(int(primaryCap), 1), overflowCap: int(overflowCap)}
+	m.notEmpty = sync.NewCond(&m.mu)
+	m.notFull = sync.NewCond(&m.mu)
+	return m
+}
+
+func (m *overflowMailbox) tryPut(req request) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return false, rpc.ErrShutdown
+	}
+	return m.push(req), nil
+}
+
+func (m *overflowMailbox) put(req request) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for !m.closed && !m.push(req) {
+		m.notFull.Wait()
+	}
+	if m.closed {
+		return rpc.ErrShutdown
+	}
+	return nil
+}
+
+// Returns false if both queues are full
+func (m *overflowMailbox) push(req request) bool {
+	switch {
+	case len(m.primary) < m.primaryCap:
+		m.primary = append(m.primary, req)
+	case len(m.overflow) < m.overflowCap:
+		m.overflow = append(m.overflow, req)
+	default:
+		return false
+	}
+	m.notEmpty.Signal()
+	return true
+}
+
+func (m *overflowMailbox) get() (request, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for len(m.primary) == 0 && len(m.overflow) == 0 && !m.closed {
+		m.notEmpty.Wait()
+	}
+	var req request
+	switch {
+	case len(m.primary) > 0:
+		req, m.primary = m.primary[0], m.primary[1:]
+	case len(m.overflow) > 0:
+		req, m.overflow = m.overflow[0], m.overflow[1:]
+	default:
+		return request{}, false
+	}
+	m.notFull.Signal()
+	return req, true
+}
+
+func (m *overflowMailbox) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.primary) + len(m.overflow)
+}
+
+func (m *overflowMailbox) cap() int {
+	return m.primaryCap + m.overflowCap
+}
+
+func (m *overflowMailbox) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	m.notEmpty.Broadcast()
+	m.notFull.Broadcast()
+}
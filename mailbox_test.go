@@ -0,0 +1,181 @@
+package genserver
+
+import (
+	"net/rpc"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFairQueuing(t *testing.T) {
+	t.Run("should not starve quiet tenant when another one floods the mailbox", func(t *testing.T) {
+		// arrange
+		s := Build(func(genserv GenServer) *TenantServer {
+			return &TenantServer{GenServer: genserv}
+		}, WithFairQueuing(func(req Request) string { return req.Meta.Tenant }))
+		defer s.Close()
+
+		done := make(chan *rpc.Call, 101)
+		for i := 0; i < 100; i++ {
+			s.CastWithMeta("work", "noisy", nil, done, Meta{Tenant: "noisy"})
+		}
+		quiet := s.CastWithMeta("work", "quiet", nil, done, Meta{Tenant: "quiet"})
+
+		// act
+		s.Start()
+		for i := 0; i < 101; i++ {
+			<-done
+		}
+
+		// assert
+		assert.Nil(t, quiet.Error)
+		assert.Len(t, s.handled, 101)
+		assert.Contains(t, s.handled[:2], "quiet")
+	})
+
+	t.Run("should keep FIFO order within a tenant", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *TenantServer {
+			return &TenantServer{GenServer: genserv}
+		}, WithFairQueuing(func(req Request) string { return req.Meta.Tenant }))
+		defer s.Close()
+
+		// act
+		done := make(chan *rpc.Call, 10)
+		for i := 0; i < 10; i++ {
+			s.CastWithMeta("work", i, nil, done, Meta{Tenant: "a"})
+		}
+		for i := 0; i < 10; i++ {
+			<-done
+		}
+		var seen []any
+		err := s.Snapshot(func(Behaviour) { seen = s.handled })
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, []any{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, seen)
+	})
+}
+
+func TestPriorityQueue(t *testing.T) {
+	t.Run("should handle requests by priority", func(t *testing.T) {
+		// arrange
+		s := Build(func(genserv GenServer) *TenantServer {
+			return &TenantServer{GenServer: genserv}
+		}, WithPriorityQueue(16))
+		defer s.Close()
+
+		done := make(chan *rpc.Call, 5)
+		s.CastWithMeta("work", "low", nil, done, Meta{Priority: 1})
+		s.CastWithMeta("work", "high", nil, done, Meta{Priority: 10})
+		s.CastWithMeta("work", "default", nil, done, Meta{})
+		s.CastWithMeta("work", "medium", nil, done, Meta{Priority: 5})
+		s.CastWithMeta("work", "high again", nil, done, Meta{Priority: 10})
+
+		// act
+		s.Start()
+		for i := 0; i < 5; i++ {
+			<-done
+		}
+		var seen []any
+		err := s.Snapshot(func(Behaviour) { seen = s.handled })
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, []any{"high", "high again", "medium", "low", "default"}, seen)
+	})
+}
+
+func TestMethodPriority(t *testing.T) {
+	t.Run("should serve read ahead of queued writes", func(t *testing.T) {
+		// arrange
+		s := Build(func(genserv GenServer) *TenantServer {
+			return &TenantServer{GenServer: genserv}
+		}, WithMethodPriority(map[string]int{"value": 1}))
+		defer s.Close()
+
+		done := make(chan *rpc.Call, 12)
+		for i := 0; i < 5; i++ {
+			s.Cast("+", i, nil, done)
+		}
+		s.Cast("value", "read", nil, done)
+		for i := 5; i < 10; i++ {
+			s.Cast("+", i, nil, done)
+		}
+		s.CastWithMeta("+", "urgent", nil, done, Meta{Priority: 2})
+
+		// act
+		s.Start()
+		for i := 0; i < 12; i++ {
+			<-done
+		}
+		var seen []any
+		err := s.Snapshot(func(Behaviour) { seen = s.handled })
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, []any{"urgent", "read", 0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, seen)
+	})
+}
+
+func TestOverflow(t *testing.T) {
+	t.Run("should spill burst into overflow queue and serve it after the mailbox drains", func(t *testing.T) {
+		// arrange
+		metrics := &metricsRecorder{}
+		genserv := newGenServer(2, 0, WithOverflow(3), WithMetrics(metrics))
+		s := &TenantServer{GenServer: genserv}
+		genserv.setBehaviour(s)
+		defer s.Close()
+
+		// act
+		done := make(chan *rpc.Call, 5)
+		for i := 0; i < 5; i++ {
+			s.Cast("work", i, nil, done)
+		}
+		pending := s.Stats().QueueLen
+		s.Start()
+		for i := 0; i < 5; i++ {
+			<-done
+		}
+		var seen []any
+		err := s.Snapshot(func(Behaviour) { seen = s.handled })
+
+		// assert
+		assert.Equal(t, 5, pending)
+		assert.Zero(t, metrics.MaxEnqueueBlock())
+		assert.Nil(t, err)
+		assert.Equal(t, []any{0, 1, 2, 3, 4}, seen)
+	})
+
+	t.Run("should take overflow requests only when the primary queue is empty", func(t *testing.T) {
+		// arrange
+		m := newOverflowMailbox(2, 2)
+		m.tryPut(request{seq: 1})
+		m.tryPut(request{seq: 2})
+		m.tryPut(request{seq: 3}) // spills
+
+		// act
+		first, _ := m.get()
+		m.tryPut(request{seq: 4}) // fits into the primary queue again
+		var rest []uint64
+		for i := 0; i < 3; i++ {
+			req, _ := m.get()
+			rest = append(rest, req.seq)
+		}
+
+		// assert
+		assert.Equal(t, uint64(1), first.seq)
+		assert.Equal(t, []uint64{2, 4, 3}, rest)
+	})
+}
+
+// Records bodies of handled requests in order
+type TenantServer struct {
+	GenServer
+	handled []any
+}
+
+func (s *TenantServer) Handle(_ string, _ uint64, body any) (any, error) {
+	s.handled = append(s.handled, body)
+	return nil, nil
+}
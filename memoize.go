@@ -0,0 +1,66 @@
+package genserver
+
+import "time"
+
+// Successful replies cached by `WithMemoize`. Accessed only by the listen loop, so it's not synchronized
+type memo struct {
+	keyFn   func(serviceMethod string, body any) (string, bool)
+	ttl     time.Duration
+	clock   Clock
+	entries map[string]memoEntry
+	sweepAt int // number of entries at which the expired ones are swept, so distinct keys don't grow the map forever
+}
+
+// Smallest number of entries swept for expired ones
+const memoSweepMin = 64
+
+type memoEntry struct {
+	value     any
+	expiresAt time.Time
+}
+
+// Returns nil if memoization is disabled
+func newMemo(keyFn func(serviceMethod string, body any) (string, bool), ttl time.Duration, clock Clock) *memo {
+	if keyFn == nil {
+		return nil
+	}
+	return &memo{keyFn: keyFn, ttl: ttl, clock: clock, entries: make(map[string]memoEntry), sweepAt: memoSweepMin}
+}
+
+// Returns a copy of the cached reply of the request, so callers don't share it, see `clone`,
+// and the key to cache its reply under on a miss
+func (m *memo) lookup(req request) (value any, key string, hit bool) {
+	if m == nil {
+		return nil, "", false
+	}
+	key, ok := m.keyFn(req.serviceMethod, req.body)
+	if !ok {
+		return nil, "", false
+	}
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, key, false
+	}
+	if !m.clock.Now().Before(entry.expiresAt) {
+		delete(m.entries, key)
+		return nil, key, false
+	}
+	return clone(entry.value), key, true
+}
+
+func (m *memo) store(key string, value any) {
+	if m == nil || key == "" {
+		return
+	}
+	now := m.clock.Now()
+	if len(m.entries) >= m.sweepAt {
+		for k, entry := range m.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(m.entries, k)
+			}
+		}
+		m.sweepAt = max(2*len(m.entries), memoSweepMin)
+	}
+	// a copy, the caller of the request gets `value` itself
+	m.entries[key] = memoEntry{value: clone(value), expiresAt: now.Add(m.ttl)}
+}
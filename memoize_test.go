@@ -0,0 +1,134 @@
+package genserver
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoize(t *testing.T) {
+	key := func(serviceMethod string, body any) (string, bool) {
+		if serviceMethod != "square" {
+			return "", false
+		}
+		return fmt.Sprint(serviceMethod, body), true
+	}
+
+	t.Run("should serve repeated request from cache", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *SquareServer {
+			return &SquareServer{GenServer: genserv}
+		}, WithMemoize(key, time.Minute))
+		defer s.Close()
+
+		// act
+		var v, v2 int
+		err := s.Call("square", 3, &v)
+		err2 := s.Call("square", 3, &v2)
+		var computed int
+		s.Snapshot(func(Behaviour) { computed = s.computed })
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, err2)
+		assert.Equal(t, 9, v)
+		assert.Equal(t, 9, v2)
+		assert.Equal(t, 1, computed)
+	})
+
+	t.Run("should compute again after ttl", func(t *testing.T) {
+		// arrange
+		clock := NewFakeClock()
+		s := Listen(func(genserv GenServer) *SquareServer {
+			return &SquareServer{GenServer: genserv}
+		}, WithMemoize(key, time.Minute), WithClock(clock))
+		defer s.Close()
+
+		// act
+		s.Call("square", 3, nil)
+		clock.Advance(time.Minute)
+		s.Call("square", 3, nil)
+		s.Call("square", 4, nil)
+		var computed int
+		s.Snapshot(func(Behaviour) { computed = s.computed })
+
+		// assert
+		assert.Equal(t, 3, computed)
+	})
+
+	t.Run("should not cache errors", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *SquareServer {
+			return &SquareServer{GenServer: genserv}
+		}, WithMemoize(key, time.Minute))
+		defer s.Close()
+
+		// act
+		err := s.Call("square", "three", nil)
+		err2 := s.Call("square", "three", nil)
+		var computed int
+		s.Snapshot(func(Behaviour) { computed = s.computed })
+
+		// assert
+		assert.NotNil(t, err)
+		assert.NotNil(t, err2)
+		assert.Equal(t, 2, computed)
+	})
+}
+
+func TestMemo(t *testing.T) {
+	key := func(serviceMethod string, body any) (string, bool) {
+		return fmt.Sprint(serviceMethod, body), true
+	}
+
+	t.Run("should sweep expired entries of distinct keys", func(t *testing.T) {
+		// arrange
+		clock := NewFakeClock()
+		m := newMemo(key, time.Minute, clock)
+		for i := 0; i < memoSweepMin; i++ {
+			m.store(fmt.Sprint(i), i)
+		}
+		clock.Advance(time.Minute)
+
+		// act
+		m.store("fresh", 0)
+
+		// assert
+		assert.Len(t, m.entries, 1)
+	})
+
+	t.Run("should not let callers share cached reply", func(t *testing.T) {
+		// arrange
+		m := newMemo(key, time.Minute, NewFakeClock())
+		reply := []int{1, 2}
+		_, k, _ := m.lookup(request{serviceMethod: "list"})
+		m.store(k, reply)
+
+		// act
+		reply[0] = -1
+		cached, _, _ := m.lookup(request{serviceMethod: "list"})
+		cached.([]int)[1] = -2
+		cached2, _, hit := m.lookup(request{serviceMethod: "list"})
+
+		// assert
+		assert.True(t, hit)
+		assert.Equal(t, []int{1, 2}, cached2)
+	})
+}
+
+// Counts how many times it has computed a square
+type SquareServer struct {
+	GenServer
+	computed int
+}
+
+func (s *SquareServer) Handle(_ string, _ uint64, body any) (any, error) {
+	s.computed++
+	n, ok := body.(int)
+	if !ok {
+		return nil, fmt.Errorf("not a number: %v", body)
+	}
+	return n * n, nil
+}
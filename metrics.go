@@ -0,0 +1,27 @@
+package genserver
+
+import "time"
+
+// Metrics receives internal measurements of a server process.
+// Implementations must be thread safe: methods are called from the caller's goroutines
+type Metrics interface {
+	// Called when `Cast` or `Call` had to wait for a free slot in the mailbox.
+	// `dur` is measured from the first attempt to the successful send
+	ObserveEnqueueBlock(dur time.Duration)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveEnqueueBlock(time.Duration) {}
+
+// Point-in-time counters of a server process, see `GenServer.Stats`
+type ServerStats struct {
+	// Requests handled by the behaviour, including the failed ones
+	Handled uint64
+	// Requests the behaviour failed to handle
+	Errors uint64
+	// Requests waiting in the mailbox
+	QueueLen int
+	// Time since the server process started handling requests, zero if it hasn't started
+	Uptime time.Duration
+}
@@ -0,0 +1,46 @@
+package genserver
+
+import "slices"
+
+// Transforms or inspects the reply of a request before it's sent to the caller, e.g. to normalize errors
+type ReplyMiddleware func(serviceMethod string, seq uint64, value any, err error) (any, error)
+
+// Returns the behaviour with `mw` applied to its replies, the first one sees the reply of the behaviour first.
+// Pass the result to `GenServer.Listen`, or return it from the constructor of `Listen`: the server process
+// unwraps it, so the optional contracts of the behaviour are still honoured. Replies the server process sends
+// on its behalf, e.g. `ErrMethodTimeout`, go through `mw` too, but `Postpone` and `Redirect` don't
+func WrapReply(behaviour Behaviour, mw ...ReplyMiddleware) Behaviour {
+	if w, ok := behaviour.(*replyWrapper); ok {
+		return &replyWrapper{Behaviour: w.Behaviour, mw: append(slices.Clip(w.mw), mw...)}
+	}
+	return &replyWrapper{Behaviour: behaviour, mw: mw}
+}
+
+type replyWrapper struct {
+	Behaviour
+	mw []ReplyMiddleware
+}
+
+// Serves the wrapper if it's used outside of a server process
+func (w *replyWrapper) Handle(serviceMethod string, seq uint64, body any) (any, error) {
+	v, err := w.Behaviour.Handle(serviceMethod, seq, body)
+	return w.apply(serviceMethod, seq, v, err)
+}
+
+func (w *replyWrapper) apply(serviceMethod string, seq uint64, v any, err error) (any, error) {
+	if w == nil {
+		return v, err
+	}
+	for _, mw := range w.mw {
+		v, err = mw(serviceMethod, seq, v, err)
+	}
+	return v, err
+}
+
+// Separates the middleware from the behaviour it wraps
+func unwrapReply(behaviour Behaviour) (Behaviour, *replyWrapper) {
+	if w, ok := behaviour.(*replyWrapper); ok {
+		return w.Behaviour, w
+	}
+	return behaviour, nil
+}
@@ -0,0 +1,82 @@
+package genserver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapReply(t *testing.T) {
+	prefixErrors := func(_ string, _ uint64, v any, err error) (any, error) {
+		if err != nil {
+			return nil, fmt.Errorf("kv: %w", err)
+		}
+		return v, nil
+	}
+
+	t.Run("should let callers see transformed errors", func(t *testing.T) {
+		// arrange
+		var s *LazyStoreServer
+		Listen(func(genserv GenServer) Behaviour {
+			s = &LazyStoreServer{GenServer: genserv, data: make(map[string]int)}
+			return WrapReply(s, prefixErrors)
+		})
+		defer s.Close()
+
+		// act
+		err := s.Call("truncate", nil, nil)
+		err2 := s.Call("put", KeyValuePair{Key: "foo", Value: 1}, nil)
+
+		// assert
+		assert.EqualError(t, err, "kv: unknown method")
+		assert.Nil(t, err2)
+	})
+
+	t.Run("should apply middleware in order", func(t *testing.T) {
+		// arrange
+		genserv := NewGenServer()
+		s := &EchoServer{GenServer: genserv}
+		double := func(_ string, _ uint64, v any, err error) (any, error) {
+			return v.(int) * 2, err
+		}
+		inc := func(_ string, _ uint64, v any, err error) (any, error) {
+			return v.(int) + 1, err
+		}
+		go genserv.Listen(WrapReply(WrapReply(s, double), inc))
+		defer s.Close()
+
+		// act
+		var reply int
+		err := s.Call("echo", 3, &reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 7, reply)
+	})
+
+	t.Run("should not transform postponed requests", func(t *testing.T) {
+		// arrange
+		var s *LazyStoreServer
+		var seen []error
+		Listen(func(genserv GenServer) Behaviour {
+			s = &LazyStoreServer{GenServer: genserv, data: make(map[string]int)}
+			return WrapReply(s, func(_ string, _ uint64, v any, err error) (any, error) {
+				seen = append(seen, err)
+				return v, err
+			})
+		})
+		defer s.Close()
+
+		// act
+		var value int
+		get := s.Cast("get", "foo", &value, nil)
+		s.Call("put", KeyValuePair{Key: "foo", Value: 1}, nil)
+		<-get.Done
+
+		// assert
+		assert.Nil(t, get.Error)
+		assert.Equal(t, 1, value)
+		assert.Equal(t, []error{nil, nil}, seen) // the put and the replayed get
+	})
+}
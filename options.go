@@ -0,0 +1,436 @@
+package genserver
+
+import (
+	"io"
+	"time"
+)
+
+type Option func(*options)
+
+// Defines what happens to requests sent before `InitBehaviour.Init` has completed
+type InitPolicy int
+
+const (
+	// Requests are kept in the mailbox and handled once `Init` has completed
+	InitHold InitPolicy = iota
+	// Requests are rejected with `ErrNotReady`
+	InitReject
+)
+
+// Defines what happens when `Behaviour.Handle` panics
+type PanicPolicy int
+
+const (
+	// The panic is converted to an error reply and the server process keeps handling requests
+	PanicIsolate PanicPolicy = iota
+	// The panic is converted to an error reply and the server process terminates, see `GenServer.Done`
+	PanicRestart
+	// The panic is propagated and crashes the program
+	PanicCrash
+)
+
+type options struct {
+	metrics           Metrics
+	postponeLimit     int
+	replyDiagnostics  func(warning string)
+	panicPolicy       PanicPolicy
+	orphanHandler     func(seq uint64, serviceMethod string, value any, err error)
+	fairKey           func(Request) string
+	eventsCapacity    int
+	eventsOverflow    EventOverflow
+	initPolicy        InitPolicy
+	watchdogTimeout   time.Duration
+	onStuck           func(serviceMethod string, seq uint64)
+	journalCapacity   int
+	priorityCapacity  int
+	singleFlightKey   func(serviceMethod string, body any) (string, bool)
+	deepCopy          func(any) any
+	clock             Clock
+	bulkhead          int
+	values            []serverValue
+	overflowCapacity  int
+	knownMethods      map[string]struct{}
+	messageTTL        time.Duration
+	tickInterval      time.Duration
+	recorder          io.Writer
+	captureNilReplies bool
+	validator         func(serviceMethod string, body any) error
+	deadlockThreshold time.Duration
+	onDeadlock        func(serviceMethod string, queued int)
+	methodNormalizer  func(string) string
+	redirectLimit     int
+	memoizeKey        func(serviceMethod string, body any) (string, bool)
+	memoizeTTL        time.Duration
+	lowWater          float64
+	highWater         float64
+	onHandlerError    func(serviceMethod string, seq uint64, err error)
+	replyIsolation    bool
+	deliveryJournal   DeliveryJournal
+	methodTimeouts    map[string]time.Duration
+	encoding          Encoding
+	methodPriority    map[string]int
+	onBusy            func()
+	onIdle            func()
+	interceptor       func(req *Request) error
+	maxRequests       uint64
+	cowInitial        any
+	cowApply          func(state any, serviceMethod string, body any) (any, any, error)
+	noReentrancyCheck bool
+}
+
+type serverValue struct {
+	key, value any
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		metrics:          nopMetrics{},
+		postponeLimit:    64,
+		redirectLimit:    8,
+		replyDiagnostics: func(string) {},
+		orphanHandler:    func(uint64, string, any, error) {},
+		onHandlerError:   func(string, uint64, error) {},
+		onBusy:           func() {},
+		onIdle:           func() {},
+		eventsCapacity:   -1,
+		clock:            realClock{},
+		encoding:         EncodingGob,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Reports internal measurements of a server process to `m`
+func WithMetrics(m Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// Sets how many times a single request can be postponed before it fails with `ErrPostponeLimit`
+func WithPostponeLimit(n int) Option {
+	return func(o *options) {
+		o.postponeLimit = n
+	}
+}
+
+// Sets how many times a single request can be redirected before it fails with `ErrRedirectLimit`
+func WithRedirectLimit(n int) Option {
+	return func(o *options) {
+		o.redirectLimit = n
+	}
+}
+
+// Reports replies that were silently ignored because the caller's `reply` is not a pointer
+// or can't hold the value returned by the behaviour. Useful to catch wiring mistakes in tests
+func WithReplyDiagnostics(f func(warning string)) Option {
+	return func(o *options) {
+		o.replyDiagnostics = f
+	}
+}
+
+func WithPanicPolicy(p PanicPolicy) Option {
+	return func(o *options) {
+		o.panicPolicy = p
+	}
+}
+
+// Receives responses that arrived after the caller stopped waiting for them, see `GenServer.CallContext`
+func WithOrphanHandler(f func(seq uint64, serviceMethod string, value any, err error)) Option {
+	return func(o *options) {
+		o.orphanHandler = f
+	}
+}
+
+// Buckets the mailbox by `keyFn` (e.g. `Meta.Tenant`) and takes requests round-robin across buckets
+// instead of strict FIFO, so one noisy key can't starve the others
+func WithFairQueuing(keyFn func(Request) string) Option {
+	return func(o *options) {
+		o.fairKey = keyFn
+	}
+}
+
+// Replaces the FIFO mailbox with a priority queue of capacity `n`: the pending request with the highest
+// `Meta.Priority` is handled first, requests of equal priority are handled in the order they were sent.
+// Takes precedence over `WithFairQueuing`
+func WithPriorityQueue(n int) Option {
+	return func(o *options) {
+		o.priorityCapacity = n
+	}
+}
+
+// Ranks requests by their method, e.g. reads above writes, so they are handled ahead of lower ranked ones
+// waiting in the mailbox. Unranked methods fall back to `Meta.Priority`, ties are handled in the order they were sent.
+// Replaces the FIFO mailbox with a priority queue, of the capacity set by `WithPriorityQueue` if any.
+// Takes precedence over `WithFairQueuing`
+func WithMethodPriority(priorities map[string]int) Option {
+	return func(o *options) {
+		o.methodPriority = priorities
+	}
+}
+
+// Enables `GenServer.Events`. The channel is buffered by `capacity`, `overflow` defines what happens when it's full
+func WithEvents(capacity int, overflow EventOverflow) Option {
+	return func(o *options) {
+		o.eventsCapacity = capacity
+		o.eventsOverflow = overflow
+	}
+}
+
+func WithInitPolicy(p InitPolicy) Option {
+	return func(o *options) {
+		o.initPolicy = p
+	}
+}
+
+// Calls `onStuck` from another goroutine if a single `Behaviour.Handle` runs longer than `d`.
+// The handler can't be preempted, but `onStuck` may e.g. close the server process so a supervisor restarts it
+func WithHandlerWatchdog(d time.Duration, onStuck func(serviceMethod string, seq uint64)) Option {
+	return func(o *options) {
+		o.watchdogTimeout = d
+		o.onStuck = onStuck
+	}
+}
+
+// Maps the service method of every request before anything else sees it, e.g. to make names
+// case-insensitive or to alias "fetch" to "get". The behaviour, hooks and the journal get the mapped name
+func WithMethodNormalizer(normalize func(serviceMethod string) string) Option {
+	return func(o *options) {
+		o.methodNormalizer = normalize
+	}
+}
+
+// Calls `onDeadlock` from another goroutine if the listen loop hasn't dequeued or completed a request for `d`
+// while requests are waiting in the mailbox. `serviceMethod` is the request the loop is stuck on, empty if it's
+// stuck outside of the behaviour. Unlike `WithHandlerWatchdog` it's reported once per stall of the whole loop
+func WithDeadlockDetector(d time.Duration, onDeadlock func(serviceMethod string, queued int)) Option {
+	return func(o *options) {
+		o.deadlockThreshold = d
+		o.onDeadlock = onDeadlock
+	}
+}
+
+// Keeps the last `n` handled requests in memory, see `GenServer.RecentRequests`
+func WithJournal(n int) Option {
+	return func(o *options) {
+		o.journalCapacity = n
+	}
+}
+
+// Collapses duplicate requests: while a request with a key is in flight, requests with the same key
+// are not handled but wait for its reply. `keyFn` returns false for requests that must not be collapsed
+func WithSingleFlight(keyFn func(serviceMethod string, body any) (string, bool)) Option {
+	return func(o *options) {
+		o.singleFlightKey = keyFn
+	}
+}
+
+// Calls `f` on the server process whenever the behaviour fails a request, including panics recovered
+// by `PanicIsolate`, before the error is sent to the caller. `f` must be quick, the listen loop waits for it
+func WithOnHandlerError(f func(serviceMethod string, seq uint64, err error)) Option {
+	return func(o *options) {
+		o.onHandlerError = f
+	}
+}
+
+// Rejects a growing fraction of casts with `ErrOverloaded` while the mailbox is filled above `highWater`,
+// and eases off while it's below `lowWater`. Both are fractions of the mailbox capacity, e.g. 0.5 and 0.25.
+// Blocking calls are never shed, their callers already slow down with the server process
+func WithAdaptiveShedding(lowWater, highWater float64) Option {
+	return func(o *options) {
+		o.lowWater = lowWater
+		o.highWater = highWater
+	}
+}
+
+// Caches successful replies of the behaviour for `ttl`, so repeated requests with the same key are replied
+// by the server process without calling the behaviour. Meant for expensive pure queries.
+// `keyFn` returns false for requests that must not be cached. Each caller gets its own deep copy of a cached reply,
+// except for unexported struct fields, channels and functions, which stay shared
+func WithMemoize(keyFn func(serviceMethod string, body any) (string, bool), ttl time.Duration) Option {
+	return func(o *options) {
+		o.memoizeKey = keyFn
+		o.memoizeTTL = ttl
+	}
+}
+
+// Copies request bodies when they are sent and reply values when they are delivered, so the caller
+// and the behaviour never share memory. `copyFn` must return a value of the same type.
+// By default values are passed by reference
+func WithDeepCopy(copyFn func(any) any) Option {
+	return func(o *options) {
+		o.deepCopy = copyFn
+	}
+}
+
+// Sets the budgets of the handlers of the given methods, other methods are unbounded. A request that exceeds it
+// fails with `ErrMethodTimeout`. The handler can't be preempted: the listen loop waits for it to return before
+// it handles the next request, so requests stay serialized, only the caller stops waiting
+func WithMethodTimeout(budgets map[string]time.Duration) Option {
+	return func(o *options) {
+		o.methodTimeouts = budgets
+	}
+}
+
+// Sets the journal of `GenServer.CastReliable`. Casts left unacknowledged by a crashed server process
+// are sent again, in no particular order relative to new requests, when a server process with `j` starts
+func WithDeliveryJournal(j DeliveryJournal) Option {
+	return func(o *options) {
+		o.deliveryJournal = j
+	}
+}
+
+// Deep-copies reply values before they leave the server process, so a caller can't mutate the state
+// of the behaviour through a slice, map or pointer it got in a reply. Unlike `WithDeepCopy` it needs no copy
+// function and doesn't copy request bodies. Pointers, slices, maps, arrays and exported struct fields
+// are copied recursively, unexported fields, channels and functions are shared
+func WithReplyIsolation() Option {
+	return func(o *options) {
+		o.replyIsolation = true
+	}
+}
+
+// Replaces the real clock of the server process, e.g. with a fake one in tests
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// Limits the number of goroutines blocked in `Call` and its variants on the server process.
+// Calls beyond `max` fail immediately with `ErrBulkheadFull`. `Cast` is not limited
+func WithBulkhead(max int) Option {
+	return func(o *options) {
+		o.bulkhead = max
+	}
+}
+
+// Adds a value to `GenServer.Context`. Same rules for `key` as for `context.WithValue`
+func WithServerValue(key, value any) Option {
+	return func(o *options) {
+		o.values = append(o.values, serverValue{key, value})
+	}
+}
+
+// Adds an overflow queue of capacity `n` to the mailbox. When the mailbox is full, requests spill into
+// the overflow queue instead of blocking the caller, and are handled only when the mailbox is empty.
+// Ignored if `WithPriorityQueue`, `WithMethodPriority` or `WithFairQueuing` is set
+func WithOverflow(n int) Option {
+	return func(o *options) {
+		o.overflowCapacity = n
+	}
+}
+
+// Declares the service methods handled by the behaviour. Requests of other methods are routed to
+// `FallbackBehaviour.HandleUnknown`, or fail with `ErrUnknownMethod` if the behaviour doesn't implement it
+func WithKnownMethods(methods []string) Option {
+	return func(o *options) {
+		o.knownMethods = make(map[string]struct{}, len(methods))
+		for _, method := range methods {
+			o.knownMethods[method] = struct{}{}
+		}
+	}
+}
+
+// Fails requests that have waited in the mailbox longer than `d` with `ErrRequestExpired`
+// instead of handling them. Zero disables it
+func WithMessageTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.messageTTL = d
+	}
+}
+
+// Calls `TickBehaviour.Tick` on every `interval`. Zero disables it
+func WithTick(interval time.Duration) Option {
+	return func(o *options) {
+		o.tickInterval = interval
+	}
+}
+
+// Writes every request the behaviour receives to `w` in gob encoding, so the session can be reproduced
+// by `Replay`. Concrete types of request bodies must be registered with `gob.Register`
+func WithRecorder(w io.Writer) Option {
+	return func(o *options) {
+		o.recorder = w
+	}
+}
+
+// Keeps the value returned by the behaviour in `rpc.Call.Reply` of a `Cast` sent with nil `reply`,
+// so it can be inspected as `any`
+func WithCaptureNilReplies() Option {
+	return func(o *options) {
+		o.captureNilReplies = true
+	}
+}
+
+// Lets blocking calls skip the check that fails a call made by a handler to its own server process
+// with `ErrReentrantCall`, e.g. in a hot path where no handler calls its own server process. Such a call deadlocks then
+func WithoutReentrancyCheck() Option {
+	return func(o *options) {
+		o.noReentrancyCheck = true
+	}
+}
+
+// Validates requests before they are put into the mailbox. A request that fails validation is never handled,
+// the caller gets the error of `validate`
+func WithRequestValidator(validate func(serviceMethod string, body any) error) Option {
+	return func(o *options) {
+		o.validator = validate
+	}
+}
+
+// Handles requests with `apply` instead of `Behaviour.Handle`. It runs on the listen loop and must not mutate
+// `state`, it returns a new one instead. The new state is published atomically once `apply` succeeds,
+// so readers get it with `GenServer.LoadState` bypassing the mailbox, consistent as of the last write
+func WithCopyOnWriteState(initial any, apply func(state any, serviceMethod string, body any) (newState any, reply any, err error)) Option {
+	return func(o *options) {
+		o.cowInitial = initial
+		o.cowApply = apply
+	}
+}
+
+// Called on the listen loop when it takes a request while it was idle, e.g. to scale resources up.
+// Internal requests such as snapshots don't wake it. Must not block or call the server process
+func WithOnBusy(f func()) Option {
+	return func(o *options) {
+		o.onBusy = f
+	}
+}
+
+// Called on the listen loop once the mailbox is drained after `WithOnBusy` has fired, e.g. to scale resources
+// down. Postponed requests don't keep the server process busy. Must not block or call the server process
+func WithOnIdle(f func()) Option {
+	return func(o *options) {
+		o.onIdle = f
+	}
+}
+
+// Runs `intercept` on the listen loop before every request reaches the behaviour, for fault injection in tests.
+// It may modify the method, body or meta of the request, sleep to delay it and the requests queued behind it,
+// return `Drop` to swallow it, or return an error that's sent to the caller instead of handling it.
+// Duplicates of a dropped request joined by `WithSingleFlight` fail with `Drop`
+func WithRequestInterceptor(intercept func(req *Request) error) Option {
+	return func(o *options) {
+		o.interceptor = intercept
+	}
+}
+
+// Terminates the server process once it has handled `n` requests, e.g. to recycle a behaviour that leaks.
+// Requests still in the mailbox fail as after `GenServer.Close`, and `EventTerminated` carries `ErrMaxRequests`,
+// so the owner can start a fresh server process. Zero means no limit
+func WithMaxRequests(n uint64) Option {
+	return func(o *options) {
+		o.maxRequests = n
+	}
+}
+
+// Sets the wire encoding of a server process created by `NewRemoteGenServer`, e.g. `EncodingJSON`.
+// Any other `net/rpc` codec, such as msgpack, can be plugged in with `rpc.NewClientWithCodec`.
+// A local server process passes values as is, so the option has no effect on it
+func WithEncoding(e Encoding) Option {
+	return func(o *options) {
+		o.encoding = e
+	}
+}
@@ -0,0 +1,73 @@
+package genserver
+
+import "sync"
+
+// Holds the listen loop between requests while the server process is paused, see `GenServer.Pause`
+type gate struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	paused  bool
+	busy    bool // the listen loop is handling a request
+}
+
+func newGate() *gate {
+	g := &gate{}
+	g.changed = sync.NewCond(&g.mu)
+	return g
+}
+
+// Called by the listen loop before it handles a request, blocks while the server process is paused
+func (g *gate) enter() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for g.paused {
+		g.changed.Wait()
+	}
+	g.busy = true
+}
+
+// Called by the listen loop after it has handled a request
+func (g *gate) leave() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.busy = false
+	g.changed.Broadcast()
+}
+
+// Blocks until the request being handled completes, unless `wait` is false
+func (g *gate) pause(wait bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.paused = true
+	for wait && g.busy {
+		g.changed.Wait()
+	}
+}
+
+func (g *gate) resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.paused = false
+	g.changed.Broadcast()
+}
+
+// Runs `f` in place of the listen loop if the server process is paused, once the request being handled
+// completes. The listen loop is held until `f` returns. Reports whether `f` was run
+func (g *gate) hold(f func()) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for g.paused && g.busy {
+		g.changed.Wait()
+	}
+	if !g.paused {
+		return false
+	}
+	f()
+	return true
+}
+
+func (g *gate) isPaused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
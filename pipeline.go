@@ -0,0 +1,27 @@
+package genserver
+
+// Returns a server process that passes the body of a request through `stages` in order: the reply of a stage
+// is the body of the request to the next one, and the reply of the last stage is the reply of the pipeline.
+// Every stage gets the service method of the original request. The first error stops the pipeline.
+// Closing the pipeline doesn't close the stages
+func Pipeline(stages ...GenServer) GenServer {
+	return Listen(func(genserv GenServer) *pipeline {
+		return &pipeline{GenServer: genserv, stages: stages}
+	})
+}
+
+type pipeline struct {
+	GenServer
+	stages []GenServer
+}
+
+func (p *pipeline) Handle(serviceMethod string, _ uint64, body any) (any, error) {
+	for _, stage := range p.stages {
+		var reply any
+		if err := stage.Call(serviceMethod, body, &reply); err != nil {
+			return nil, err
+		}
+		body = reply
+	}
+	return body, nil
+}
@@ -0,0 +1,71 @@
+package genserver
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeline(t *testing.T) {
+	newStage := func(transform func(any) (any, error)) GenServer {
+		return Listen(func(genserv GenServer) *StageServer {
+			return &StageServer{GenServer: genserv, transform: transform}
+		})
+	}
+	double := func(body any) (any, error) {
+		return body.(int) * 2, nil
+	}
+	stringify := func(body any) (any, error) {
+		return strconv.Itoa(body.(int)), nil
+	}
+
+	t.Run("should pass reply of each stage to the next one", func(t *testing.T) {
+		// arrange
+		stages := []GenServer{newStage(double), newStage(stringify)}
+		s := Pipeline(stages...)
+		defer CloseAll(append(stages, s)...)
+
+		// act
+		var reply string
+		err := s.Call("transform", 21, &reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "42", reply)
+	})
+
+	t.Run("should stop at first failed stage", func(t *testing.T) {
+		// arrange
+		expectedErr := errors.New("invalid")
+		var reached bool
+		stages := []GenServer{
+			newStage(func(any) (any, error) { return nil, expectedErr }),
+			newStage(func(body any) (any, error) {
+				reached = true
+				return body, nil
+			}),
+		}
+		s := Pipeline(stages...)
+		defer CloseAll(append(stages, s)...)
+
+		// act
+		err := s.Call("transform", 21, nil)
+		stages[1].Snapshot(func(Behaviour) {})
+
+		// assert
+		assert.ErrorIs(t, err, expectedErr)
+		assert.False(t, reached)
+	})
+}
+
+// Replies with the transformed body
+type StageServer struct {
+	GenServer
+	transform func(any) (any, error)
+}
+
+func (s *StageServer) Handle(_ string, _ uint64, body any) (any, error) {
+	return s.transform(body)
+}
@@ -0,0 +1,90 @@
+package genserver
+
+import (
+	"net/rpc"
+	"sync"
+)
+
+// Number of progress reports kept for a caller that doesn't keep up, older ones are dropped
+const progressBuffer = 64
+
+// Intermediate state of a long-running request, see `GenServer.CallWithProgress`
+type Progress struct {
+	Completed int
+	Total     int
+}
+
+// Progress reports of a request sent by `CallWithProgress`
+type progressSink struct {
+	mu     sync.Mutex
+	ch     chan Progress
+	closed bool
+}
+
+func newProgressSink() *progressSink {
+	return &progressSink{ch: make(chan Progress, progressBuffer)}
+}
+
+// Never blocks: when the buffer is full the oldest report is dropped. Reports made after close are ignored
+func (p *progressSink) report(progress Progress) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	for {
+		select {
+		case p.ch <- progress:
+			return
+		default:
+			select {
+			case <-p.ch:
+			default:
+			}
+		}
+	}
+}
+
+func (p *progressSink) close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.ch)
+	}
+}
+
+func (s *genServer) CallWithProgress(serviceMethod string, args any) (<-chan Progress, func() (any, error)) {
+	sink := newProgressSink()
+	if s.codec.reentrantCall() {
+		sink.close()
+		return sink.ch, func() (any, error) { return nil, ErrReentrantCall }
+	}
+	if !s.acquire() {
+		sink.close()
+		return sink.ch, func() (any, error) { return nil, ErrBulkheadFull }
+	}
+	var reply any
+	call := s.cast(serviceMethod, &envelope{args: args, progress: sink}, &reply, make(chan *rpc.Call, 1))
+	completed := make(chan struct{})
+	go func() {
+		defer close(completed)
+		defer s.release()
+		<-call.Done
+		sink.close() // the request may never reach the behaviour
+	}()
+	return sink.ch, func() (any, error) {
+		<-completed
+		return reply, call.Error
+	}
+}
+
+func (s *genServer) ReportProgress(progress Progress) {
+	s.codec.reporting.report(progress)
+}
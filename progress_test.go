@@ -0,0 +1,87 @@
+package genserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallWithProgress(t *testing.T) {
+	t.Run("should report progress before final result", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *ImportServer {
+			return &ImportServer{GenServer: genserv, store: make(map[int]struct{})}
+		})
+		defer s.Close()
+		items := make([]int, 100)
+		for i := range items {
+			items[i] = i
+		}
+
+		// act
+		progress, result := s.CallWithProgress("import", items)
+		var reports []Progress
+		for p := range progress {
+			reports = append(reports, p)
+		}
+		count, err := result()
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 100, count)
+		assert.Len(t, reports, 10)
+		assert.Equal(t, Progress{Completed: 10, Total: 100}, reports[0])
+		assert.Equal(t, Progress{Completed: 100, Total: 100}, reports[9])
+	})
+
+	t.Run("should close progress if request fails", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *ImportServer {
+			return &ImportServer{GenServer: genserv, store: make(map[int]struct{})}
+		})
+		s.Close()
+		<-s.Done()
+
+		// act
+		progress, result := s.CallWithProgress("import", []int{1})
+		_, open := <-progress
+		_, err := result()
+
+		// assert
+		assert.False(t, open)
+		assert.Error(t, err)
+	})
+
+	t.Run("should ignore progress of plain calls", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *ImportServer {
+			return &ImportServer{GenServer: genserv, store: make(map[int]struct{})}
+		})
+		defer s.Close()
+
+		// act
+		var count int
+		err := s.Call("import", []int{1, 2, 3}, &count)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 3, count)
+	})
+}
+
+// Imports items in bulk, reporting progress every 10 items
+type ImportServer struct {
+	GenServer
+	store map[int]struct{}
+}
+
+func (s *ImportServer) Handle(_ string, _ uint64, body any) (any, error) {
+	items := body.([]int)
+	for i, item := range items {
+		s.store[item] = struct{}{}
+		if (i+1)%10 == 0 {
+			s.ReportProgress(Progress{Completed: i + 1, Total: len(items)})
+		}
+	}
+	return len(s.store), nil
+}
@@ -0,0 +1,65 @@
+package genserver
+
+import "sync"
+
+// Number of events kept for a subscriber that doesn't keep up, older ones are dropped
+const subscriberBuffer = 64
+
+// Subscribers of the events published by the behaviour, grouped by topic
+type topics struct {
+	mu     sync.Mutex
+	subs   map[string][]chan any
+	closed bool
+}
+
+func newTopics() *topics {
+	return &topics{subs: make(map[string][]chan any)}
+}
+
+func (t *topics) subscribe(topic string) <-chan any {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ch := make(chan any, subscriberBuffer)
+	if t.closed {
+		close(ch)
+		return ch
+	}
+	t.subs[topic] = append(t.subs[topic], ch)
+	return ch
+}
+
+// Never blocks: when the buffer of a subscriber is full its oldest event is dropped
+func (t *topics) publish(topic string, event any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, ch := range t.subs[topic] {
+		for {
+			select {
+			case ch <- event:
+			default:
+				select {
+				case <-ch:
+				default:
+				}
+				continue
+			}
+			break
+		}
+	}
+}
+
+// Closes the channels of all subscribers, events published afterwards are dropped
+func (t *topics) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+	for _, subs := range t.subs {
+		for _, ch := range subs {
+			close(ch)
+		}
+	}
+	t.subs = nil
+}
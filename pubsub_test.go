@@ -0,0 +1,53 @@
+package genserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPubSub(t *testing.T) {
+	t.Run("should deliver event published by handler", func(t *testing.T) {
+		// arrange
+		s := NewLazyStoreServer()
+		defer s.Close()
+		events := s.Subscribe("kv.put")
+
+		// act
+		err := s.Call("put", KeyValuePair{"one", 1}, nil)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, KeyValuePair{"one", 1}, <-events)
+	})
+
+	t.Run("should drop oldest events of slow subscriber", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+		events := s.Subscribe("numbers")
+
+		// act
+		for i := 0; i < subscriberBuffer+1; i++ {
+			s.Publish("numbers", i)
+		}
+
+		// assert
+		assert.Equal(t, 1, <-events)
+		assert.Len(t, events, subscriberBuffer-1)
+	})
+
+	t.Run("should close subscription when server process terminates", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		events := s.Subscribe("numbers")
+
+		// act
+		s.Close()
+		<-s.Done()
+		_, ok := <-events
+
+		// assert
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,53 @@
+package genserver
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"log"
+)
+
+// Request as written by `WithRecorder`
+type recordedRequest struct {
+	Seq           uint64
+	ServiceMethod string
+	Body          any
+}
+
+// Writes requests in the order the behaviour receives them. Used by the listen loop only
+type recorder struct {
+	enc *gob.Encoder
+}
+
+// Returns nil if recording is disabled
+func newRecorder(w io.Writer) *recorder {
+	if w == nil {
+		return nil
+	}
+	return &recorder{enc: gob.NewEncoder(w)}
+}
+
+func (r *recorder) record(req request) {
+	if r == nil {
+		return
+	}
+	if err := r.enc.Encode(recordedRequest{Seq: req.seq, ServiceMethod: req.serviceMethod, Body: req.body}); err != nil {
+		log.Print(err)
+	}
+}
+
+// Feeds the requests recorded by `WithRecorder` to `behaviour` one by one on the calling goroutine,
+// so a session can be reproduced deterministically. Errors returned by the behaviour are ignored
+func Replay(r io.Reader, behaviour Behaviour) error {
+	dec := gob.NewDecoder(r)
+	for {
+		var req recordedRequest
+		if err := dec.Decode(&req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		behaviour.Handle(req.ServiceMethod, req.Seq, req.Body)
+	}
+}
@@ -0,0 +1,40 @@
+package genserver
+
+import (
+	"bytes"
+	"net/rpc"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplay(t *testing.T) {
+	t.Run("should replay recorded session to the same final state", func(t *testing.T) {
+		// arrange
+		var session bytes.Buffer
+		s := Listen(func(genserv GenServer) *TenantServer {
+			return &TenantServer{GenServer: genserv}
+		}, WithRecorder(&session))
+		defer s.Close()
+
+		done := make(chan *rpc.Call, 20)
+		for i := 0; i < 10; i++ {
+			go s.Cast("work", i, nil, done)
+			go s.Cast("work", "tenant", nil, done)
+		}
+		for i := 0; i < 20; i++ {
+			<-done
+		}
+		var recorded []any
+		s.Snapshot(func(Behaviour) { recorded = s.handled })
+
+		// act
+		replayed := &TenantServer{}
+		err := Replay(&session, replayed)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Len(t, replayed.handled, 20)
+		assert.Equal(t, recorded, replayed.handled)
+	})
+}
@@ -0,0 +1,26 @@
+package genserver
+
+import "errors"
+
+// Returned when a request was redirected more than `WithRedirectLimit` times, e.g. by shards pointing at each other
+var ErrRedirectLimit = errors.New("request redirected too many times")
+
+// Returned as an error by `Behaviour.Handle` to hand the request over to another server process, e.g. the shard
+// that owns the key. The request is sent to `To` as is and the caller gets its reply, as if `To` was called directly
+type Redirect struct {
+	To GenServer
+}
+
+func (r Redirect) Error() string {
+	return "request redirected"
+}
+
+// Follows the redirect without blocking the listen loop
+func (c *genServerCodec) redirect(req request, to GenServer) {
+	if req.meta.Redirects >= c.options.redirectLimit {
+		c.reply(req, nil, ErrRedirectLimit)
+		return
+	}
+	req.meta.Redirects++
+	c.forward(to, req)
+}
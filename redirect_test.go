@@ -0,0 +1,66 @@
+package genserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirect(t *testing.T) {
+	t.Run("should transparently reply from the shard owning the key", func(t *testing.T) {
+		// arrange
+		b := Listen(func(genserv GenServer) *ShardServer {
+			return &ShardServer{GenServer: genserv, name: "b"}
+		})
+		defer b.Close()
+		a := Listen(func(genserv GenServer) *ShardServer {
+			return &ShardServer{GenServer: genserv, name: "a", owners: map[string]GenServer{"two": b}}
+		})
+		defer a.Close()
+
+		// act
+		var reply, reply2 string
+		err := a.Call("get", "one", &reply)
+		err2 := a.Call("get", "two", &reply2)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, err2)
+		assert.Equal(t, "a:one", reply)
+		assert.Equal(t, "b:two", reply2)
+	})
+
+	t.Run("should stop redirect loop", func(t *testing.T) {
+		// arrange
+		a := Listen(func(genserv GenServer) *ShardServer {
+			return &ShardServer{GenServer: genserv, name: "a", owners: map[string]GenServer{}}
+		}, WithRedirectLimit(3))
+		defer a.Close()
+		b := Listen(func(genserv GenServer) *ShardServer {
+			return &ShardServer{GenServer: genserv, name: "b", owners: map[string]GenServer{"one": a}}
+		}, WithRedirectLimit(3))
+		defer b.Close()
+		a.Snapshot(func(Behaviour) { a.owners["one"] = b })
+
+		// act
+		err := a.Call("get", "one", nil)
+
+		// assert
+		assert.ErrorIs(t, err, ErrRedirectLimit)
+	})
+}
+
+// Replies with its name and the key, or redirects keys owned by other shards
+type ShardServer struct {
+	GenServer
+	name   string
+	owners map[string]GenServer
+}
+
+func (s *ShardServer) Handle(_ string, _ uint64, body any) (any, error) {
+	key := body.(string)
+	if owner, ok := s.owners[key]; ok {
+		return nil, Redirect{To: owner}
+	}
+	return s.name + ":" + key, nil
+}
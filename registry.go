@@ -0,0 +1,135 @@
+package genserver
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	// Returned by `Register` when the name is taken by a live server process
+	ErrNameTaken = errors.New("name is already registered")
+	// Returned when no live server process is registered under the name
+	ErrNotRegistered = errors.New("name is not registered")
+)
+
+// Process-wide names of server processes. A server process is dropped once it terminates, see `GenServer.Done`
+var registry = struct {
+	mu      sync.Mutex
+	servers map[string]GenServer
+}{servers: make(map[string]GenServer)}
+
+// Makes the server process reachable by `name`, see `Whereis`. The name of a terminated server process can be reused
+func Register(name string, s GenServer) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if registered, ok := registry.servers[name]; ok && alive(registered) {
+		return ErrNameTaken
+	}
+	registry.servers[name] = s
+	return nil
+}
+
+func Unregister(name string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	delete(registry.servers, name)
+}
+
+// Returns the live server process registered under `name`
+func Whereis(name string) (GenServer, bool) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	s, ok := registry.servers[name]
+	if !ok || !alive(s) {
+		return nil, false
+	}
+	return s, true
+}
+
+// Rebinds `name` from the live server process registered under it to `s`, e.g. for a blue/green upgrade.
+// Once it's rebound, `CallNamed` reaches `s`. If `drain` is set, the requests waiting in the mailbox of the old
+// server process are handed over to `s` and the old one is closed, see `GenServer.DrainTo`. Then it returns
+// once `s` has answered them, so requests that wait for later ones, e.g. postponed, must not be handed over
+func Migrate(name string, s GenServer, drain bool) error {
+	registry.mu.Lock()
+	old, ok := registry.servers[name]
+	if !ok || !alive(old) {
+		registry.mu.Unlock()
+		return ErrNotRegistered
+	}
+	registry.servers[name] = s
+	registry.mu.Unlock()
+	if !drain {
+		return nil
+	}
+	return old.DrainTo(s)
+}
+
+// Calls the server process registered under `name`. If it stops intake because of `Migrate` in the meantime,
+// the call is sent again to the server process the name is bound to now. Only `ErrServerClosing` is retried:
+// it's returned before the request reaches the mailbox, while `rpc.ErrShutdown` may follow a handled request
+func CallNamed(name string, serviceMethod string, args any, reply any) error {
+	s, ok := Whereis(name)
+	if !ok {
+		return ErrNotRegistered
+	}
+	for {
+		err := s.Call(serviceMethod, args, reply)
+		if !errors.Is(err, ErrServerClosing) {
+			return err
+		}
+		next, ok := Whereis(name)
+		if !ok || next == s {
+			return err
+		}
+		s = next
+	}
+}
+
+// Returns the sorted names of live server processes
+func RegisteredNames() []string {
+	return sortedNames(registered())
+}
+
+// Calls `f` for every live server process in the order of `RegisteredNames`. The registry is not locked
+// while `f` runs, so `f` may register or unregister names, the changes are not observed by the iteration
+func ForEachServer(f func(name string, s GenServer)) {
+	servers := registered()
+	for _, name := range sortedNames(servers) {
+		f(name, servers[name])
+	}
+}
+
+func sortedNames(servers map[string]GenServer) []string {
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Snapshot of live registered server processes, terminated ones are dropped from the registry
+func registered() map[string]GenServer {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	servers := make(map[string]GenServer, len(registry.servers))
+	for name, s := range registry.servers {
+		if !alive(s) {
+			delete(registry.servers, name)
+			continue
+		}
+		servers[name] = s
+	}
+	return servers
+}
+
+func alive(s GenServer) bool {
+	select {
+	case <-s.Done():
+		return false
+	default:
+		return true
+	}
+}
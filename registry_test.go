@@ -0,0 +1,170 @@
+package genserver
+
+import (
+	"net/rpc"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("should enumerate live servers only", func(t *testing.T) {
+		// arrange
+		one, two, three := NewEchoServer(0), NewEchoServer(0), NewEchoServer(0)
+		defer CloseAll(one, three)
+		Register("registry.one", one)
+		Register("registry.two", two)
+		Register("registry.three", three)
+		defer Unregister("registry.one")
+		defer Unregister("registry.three")
+
+		// act
+		before := RegisteredNames()
+		two.Close()
+		<-two.Done()
+		after := make(map[string]GenServer)
+		ForEachServer(func(name string, s GenServer) { after[name] = s })
+
+		// assert
+		assert.Subset(t, before, []string{"registry.one", "registry.two", "registry.three"})
+		assert.Same(t, one, after["registry.one"])
+		assert.Same(t, three, after["registry.three"])
+		assert.NotContains(t, after, "registry.two")
+	})
+
+	t.Run("should not register name taken by live server", func(t *testing.T) {
+		// arrange
+		s, other := NewEchoServer(0), NewEchoServer(0)
+		defer CloseAll(s, other)
+		Register("registry.taken", s)
+		defer Unregister("registry.taken")
+
+		// act
+		err := Register("registry.taken", other)
+		found, ok := Whereis("registry.taken")
+
+		// assert
+		assert.ErrorIs(t, err, ErrNameTaken)
+		assert.True(t, ok)
+		assert.Same(t, s, found)
+	})
+
+	t.Run("should reuse name of terminated server", func(t *testing.T) {
+		// arrange
+		s, next := NewEchoServer(0), NewEchoServer(0)
+		defer next.Close()
+		Register("registry.reused", s)
+		defer Unregister("registry.reused")
+		s.Close()
+		<-s.Done()
+
+		// act
+		err := Register("registry.reused", next)
+		found, ok := Whereis("registry.reused")
+
+		// assert
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Same(t, next, found)
+	})
+
+	t.Run("should route named calls to migrated server", func(t *testing.T) {
+		// arrange
+		newStore := func(genserv GenServer) *LazyStoreServer {
+			return &LazyStoreServer{GenServer: genserv, data: make(map[string]int)}
+		}
+		old, fresh := Listen(newStore), Listen(newStore)
+		defer CloseAll(old, fresh)
+		Register("registry.kv", old)
+		defer Unregister("registry.kv")
+		CallNamed("registry.kv", "put", KeyValuePair{Key: "foo", Value: 1}, nil)
+
+		// act
+		err := Migrate("registry.kv", fresh, false)
+		err2 := CallNamed("registry.kv", "put", KeyValuePair{Key: "bar", Value: 2}, nil)
+		var bar int
+		err3 := CallNamed("registry.kv", "get", "bar", &bar)
+		var oldData, freshData map[string]int
+		old.Snapshot(func(Behaviour) { oldData = old.data })
+		fresh.Snapshot(func(Behaviour) { freshData = fresh.data })
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, err2)
+		assert.Nil(t, err3)
+		assert.Equal(t, 2, bar)
+		assert.Equal(t, map[string]int{"foo": 1}, oldData)
+		assert.Equal(t, map[string]int{"bar": 2}, freshData)
+	})
+
+	t.Run("should not send named call again once old server has taken it", func(t *testing.T) {
+		// arrange
+		old, fresh := NewEchoServer(50*time.Millisecond), NewEchoServer(0)
+		defer fresh.Close()
+		Register("registry.echo", old)
+		defer Unregister("registry.echo")
+		errs := make(chan error, 1)
+		go func() {
+			errs <- CallNamed("registry.echo", "echo", nil, nil)
+		}()
+		for old.InFlight() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		// act
+		Migrate("registry.echo", fresh, false)
+		old.Close()
+		err := <-errs
+
+		// assert
+		assert.ErrorIs(t, err, rpc.ErrShutdown)
+		assert.Zero(t, fresh.Stats().Handled)
+	})
+
+	t.Run("should drain mailbox of old server into migrated one", func(t *testing.T) {
+		// arrange
+		old := Listen(func(genserv GenServer) *ReplicaServer {
+			return &ReplicaServer{GenServer: genserv, name: "old", delay: 100 * time.Millisecond}
+		})
+		fresh := Listen(func(genserv GenServer) *ReplicaServer {
+			return &ReplicaServer{GenServer: genserv, name: "fresh"}
+		})
+		defer fresh.Close()
+		Register("registry.kv.drain", old)
+		defer Unregister("registry.kv.drain")
+		var first, queued string
+		firstCall := old.Cast("get", nil, &first, nil)
+		time.Sleep(20 * time.Millisecond) // the first request is being handled
+		queuedCall := old.Cast("get", nil, &queued, nil)
+
+		// act
+		err := Migrate("registry.kv.drain", fresh, true)
+		var named string
+		err2 := CallNamed("registry.kv.drain", "get", nil, &named)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, err2)
+		<-firstCall.Done
+		<-queuedCall.Done
+		assert.Equal(t, "old", first)
+		assert.Equal(t, "fresh", queued)
+		assert.Equal(t, "fresh", named)
+		<-old.Done()
+	})
+
+	t.Run("should not migrate unregistered name", func(t *testing.T) {
+		// arrange
+		s := NewEchoServer(0)
+		defer s.Close()
+
+		// act
+		err := Migrate("registry.missing", s, false)
+		err2 := CallNamed("registry.missing", "echo", nil, nil)
+
+		// assert
+		assert.ErrorIs(t, err, ErrNotRegistered)
+		assert.ErrorIs(t, err2, ErrNotRegistered)
+	})
+}
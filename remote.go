@@ -0,0 +1,266 @@
+package genserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"time"
+)
+
+// Returned by the methods of a remote server process that need access to its behaviour
+var ErrRemoteUnsupported = errors.New("not supported by remote server process")
+
+// Builds the `net/rpc` client of a remote server process on top of the connection, see `WithEncoding`
+type Encoding func(conn io.ReadWriteCloser) *rpc.Client
+
+var (
+	// The default codec of `net/rpc`, args and replies must be gob-encodable
+	EncodingGob Encoding = rpc.NewClient
+	// The JSON-RPC 1.0 codec of `net/rpc/jsonrpc`, for servers written in other languages.
+	// Args are sent as a single-element params array
+	EncodingJSON Encoding = jsonrpc.NewClient
+)
+
+// Returns a `GenServer` whose requests are sent over `conn` to a `net/rpc` server, using the gob codec
+// unless another one is set by `WithEncoding`. Other options don't apply to a remote server process.
+// Service methods are in the "Service.Method" form of `net/rpc`, args and replies must be encodable.
+// The behaviour lives on the other side, so methods that reach into it fail with `ErrRemoteUnsupported`
+// or report nothing: there are no events, journal, stats or pub/sub, and `Meta` is not transmitted.
+// The channels of `Events` and `Subscribe` are closed right away.
+// `Quiesce`, `Pause`, `Resume` and `Unpause` can't return the error, they are unsupported and do nothing
+func NewRemoteGenServer(conn io.ReadWriteCloser, opts ...Option) GenServer {
+	o := newOptions(opts)
+	s := &remoteGenServer{done: make(chan struct{})}
+	s.client = o.encoding(&remoteConn{ReadWriteCloser: conn, onReadError: s.terminate})
+	return s
+}
+
+// Connection that reports a failed read, e.g. the other side hung up. `rpc.Client` reads the connection
+// until the first error, so the client is unusable from then on
+type remoteConn struct {
+	io.ReadWriteCloser
+	onReadError func()
+}
+
+func (c *remoteConn) Read(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(p)
+	if err != nil {
+		c.onReadError()
+	}
+	return n, err
+}
+
+type remoteGenServer struct {
+	client    *rpc.Client
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ GenServer = (*remoteGenServer)(nil)
+
+// The remote server process is already listening
+func (s *remoteGenServer) Listen(Behaviour) {}
+
+// Closes the connection once `ctx` is cancelled
+func (s *remoteGenServer) ListenContext(ctx context.Context, _ Behaviour) error {
+	select {
+	case <-ctx.Done():
+		s.Close()
+		return ctx.Err()
+	case <-s.done:
+		return nil
+	}
+}
+
+func (s *remoteGenServer) Cast(serviceMethod string, args any, reply any, done chan *rpc.Call) *rpc.Call {
+	return s.client.Go(serviceMethod, args, reply, done)
+}
+
+// Returns once the request is written to the connection
+func (s *remoteGenServer) CastAck(serviceMethod string, args any) error {
+	call := s.client.Go(serviceMethod, args, nil, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done: // `rpc.Client` writes the request on the caller's goroutine, so a failed write is visible here
+		return call.Error
+	default:
+		return nil
+	}
+}
+
+func (s *remoteGenServer) CastReliable(string, any) error {
+	return ErrRemoteUnsupported
+}
+
+func (s *remoteGenServer) CastCallback(serviceMethod string, args any, reply any, cb func(*rpc.Call)) {
+	call := s.Cast(serviceMethod, args, reply, make(chan *rpc.Call, 1))
+	go func() {
+		cb(<-call.Done)
+	}()
+}
+
+func (s *remoteGenServer) SendAfter(d time.Duration, serviceMethod string, args any) func() bool {
+	return sendAfter(s, realClock{}, d, serviceMethod, args)
+}
+
+func (s *remoteGenServer) Call(serviceMethod string, args any, reply any) error {
+	return s.client.Call(serviceMethod, args, reply)
+}
+
+func (s *remoteGenServer) CallInto(serviceMethod string, args any, reply any) error {
+	return s.client.Call(serviceMethod, args, reply)
+}
+
+func (s *remoteGenServer) CallFull(serviceMethod string, args any, reply any) (*rpc.Call, error) {
+	call := <-s.Cast(serviceMethod, args, reply, make(chan *rpc.Call, 1)).Done
+	return call, call.Error
+}
+
+func (s *remoteGenServer) CastWithMeta(serviceMethod string, args any, reply any, done chan *rpc.Call, _ Meta) *rpc.Call {
+	return s.Cast(serviceMethod, args, reply, done)
+}
+
+func (s *remoteGenServer) CallWithMeta(serviceMethod string, args any, reply any, _ Meta) error {
+	return s.Call(serviceMethod, args, reply)
+}
+
+func (s *remoteGenServer) CallContext(ctx context.Context, serviceMethod string, args any, reply any) error {
+	call := s.Cast(serviceMethod, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *remoteGenServer) CallTimeout(serviceMethod string, args any, reply any, timeout time.Duration) error {
+	call := s.Cast(serviceMethod, args, reply, make(chan *rpc.Call, 1))
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-timer.C:
+		return ErrCallTimeout
+	}
+}
+
+func (s *remoteGenServer) CallTimed(serviceMethod string, args any, reply any) (time.Duration, error) {
+	start := time.Now()
+	err := s.Call(serviceMethod, args, reply)
+	return time.Since(start), err
+}
+
+// Closes the connection, the remote server process keeps running
+func (s *remoteGenServer) Close() error {
+	err := s.client.Close()
+	s.terminate()
+	return err
+}
+
+func (s *remoteGenServer) terminate() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// Returns a channel that's closed when the connection is closed by `Close` or fails, e.g. the other side hung up,
+// so a registry drops the remote server process
+func (s *remoteGenServer) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *remoteGenServer) Start() error {
+	return ErrAlreadyStarted
+}
+
+func (s *remoteGenServer) Behaviour() Behaviour {
+	return nil
+}
+
+func (s *remoteGenServer) Snapshot(func(Behaviour)) error {
+	return ErrRemoteUnsupported
+}
+
+// No events are sent over the connection, the channel is closed right away
+func (s *remoteGenServer) Events() <-chan Event {
+	events := make(chan Event)
+	close(events)
+	return events
+}
+
+func (s *remoteGenServer) RecentRequests() []RequestLog {
+	return nil
+}
+
+func (s *remoteGenServer) Stats() ServerStats {
+	return ServerStats{}
+}
+
+func (s *remoteGenServer) Ping(time.Duration) (Health, error) {
+	return Health{Status: StatusDown}, ErrRemoteUnsupported
+}
+
+func (s *remoteGenServer) Uptime() time.Duration {
+	return 0
+}
+
+func (s *remoteGenServer) AbandonedCalls() int {
+	return 0
+}
+
+func (s *remoteGenServer) LoadState() any {
+	return nil
+}
+
+// No progress is reported over the connection, the channel is closed right away
+func (s *remoteGenServer) CallWithProgress(serviceMethod string, args any) (<-chan Progress, func() (any, error)) {
+	progress := make(chan Progress)
+	close(progress)
+	var reply any
+	call := s.Cast(serviceMethod, args, &reply, make(chan *rpc.Call, 1))
+	return progress, func() (any, error) {
+		<-call.Done
+		return reply, call.Error
+	}
+}
+
+func (s *remoteGenServer) ReportProgress(Progress) {}
+
+// Unsupported, intake of the remote server process can't be stopped over the connection, so it does nothing
+func (s *remoteGenServer) Quiesce() {}
+
+// Unsupported, the remote server process can't be paused over the connection, so it does nothing
+func (s *remoteGenServer) Pause() {}
+
+// Unsupported, see `Quiesce`
+func (s *remoteGenServer) Resume() {}
+
+// Unsupported, see `Pause`
+func (s *remoteGenServer) Unpause() {}
+
+func (s *remoteGenServer) DrainTo(GenServer) error {
+	return ErrRemoteUnsupported
+}
+
+func (s *remoteGenServer) InFlight() int {
+	return 0
+}
+
+func (s *remoteGenServer) WaitIdle(time.Duration) error {
+	return ErrRemoteUnsupported
+}
+
+func (s *remoteGenServer) Context() context.Context {
+	return context.Background()
+}
+
+func (s *remoteGenServer) Publish(string, any) {}
+
+// No events are published over the connection, the channel is closed right away
+func (s *remoteGenServer) Subscribe(string) <-chan any {
+	events := make(chan any)
+	close(events)
+	return events
+}
@@ -0,0 +1,216 @@
+package genserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteGenServer(t *testing.T) {
+	newRemote := func() GenServer {
+		server := rpc.NewServer()
+		server.RegisterName("Math", &RemoteMath{})
+		serverConn, clientConn := net.Pipe()
+		go server.ServeConn(serverConn)
+		return NewRemoteGenServer(clientConn)
+	}
+
+	t.Run("should call remote behaviour", func(t *testing.T) {
+		// arrange
+		s := newRemote()
+		defer s.Close()
+
+		// act
+		var reply int
+		err := s.Call("Math.Add", [2]int{2, 3}, &reply)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 5, reply)
+	})
+
+	t.Run("should cast to remote behaviour", func(t *testing.T) {
+		// arrange
+		s := newRemote()
+		defer s.Close()
+
+		// act
+		call := s.Cast("Math.Add", [2]int{1, 1}, new(int), nil)
+		<-call.Done
+
+		// assert
+		assert.Nil(t, call.Error)
+		assert.Equal(t, 2, Reply[int](call))
+	})
+
+	t.Run("should return error of remote behaviour", func(t *testing.T) {
+		// arrange
+		s := newRemote()
+		defer s.Close()
+
+		// act
+		var reply int
+		err := s.Call("Math.Div", [2]int{1, 0}, &reply)
+
+		// assert
+		assert.EqualError(t, err, errDivisionByZero.Error())
+	})
+
+	t.Run("should fail after close", func(t *testing.T) {
+		// arrange
+		s := newRemote()
+
+		// act
+		s.Close()
+		<-s.Done()
+		err := s.Call("Math.Add", [2]int{1, 1}, new(int))
+
+		// assert
+		assert.ErrorIs(t, err, rpc.ErrShutdown)
+		assert.ErrorIs(t, s.Snapshot(func(Behaviour) {}), ErrRemoteUnsupported)
+	})
+
+	t.Run("should return closed event channels", func(t *testing.T) {
+		// arrange
+		s := newRemote()
+		defer s.Close()
+
+		// act
+		var events, published int
+		for range s.Events() {
+			events++
+		}
+		for range s.Subscribe("topic") {
+			published++
+		}
+
+		// assert
+		assert.Zero(t, events)
+		assert.Zero(t, published)
+	})
+
+	t.Run("should terminate once connection is closed by the other side", func(t *testing.T) {
+		// arrange
+		server := rpc.NewServer()
+		server.RegisterName("Math", &RemoteMath{})
+		serverConn, clientConn := net.Pipe()
+		go server.ServeConn(serverConn)
+		s := NewRemoteGenServer(clientConn)
+		defer s.Close()
+		Register("remote.math", s)
+		defer Unregister("remote.math")
+
+		// act
+		serverConn.Close()
+		<-s.Done()
+		_, found := Whereis("remote.math")
+
+		// assert
+		assert.False(t, found)
+	})
+}
+
+func TestRemoteEncoding(t *testing.T) {
+	t.Run("should round trip put and get over JSON", func(t *testing.T) {
+		// arrange
+		server := rpc.NewServer()
+		server.RegisterName("KV", &RemoteKV{dict: make(map[string]string)})
+		serverConn, clientConn := net.Pipe()
+		go server.ServeCodec(jsonrpc.NewServerCodec(serverConn))
+		s := NewRemoteGenServer(clientConn, WithEncoding(EncodingJSON))
+		defer s.Close()
+
+		// act
+		var ok bool
+		err := s.Call("KV.Put", RemotePut{Key: "foo", Value: "bar"}, &ok)
+		var value string
+		err2 := s.Call("KV.Get", "foo", &value)
+		err3 := s.Call("KV.Get", "baz", &value)
+
+		// assert
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Nil(t, err2)
+		assert.Equal(t, "bar", value)
+		assert.EqualError(t, err3, errNotFound.Error())
+	})
+
+	t.Run("should send JSON-RPC on the wire", func(t *testing.T) {
+		// arrange
+		serverConn, clientConn := net.Pipe()
+		s := NewRemoteGenServer(clientConn, WithEncoding(EncodingJSON))
+		defer s.Close()
+
+		var msg map[string]any
+		decoded := make(chan error, 1)
+		go func() {
+			decoded <- json.NewDecoder(serverConn).Decode(&msg)
+		}()
+
+		// act
+		s.Cast("KV.Get", "foo", nil, nil) // the pipe is unbuffered, the write waits for the decoder
+		err := <-decoded
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "KV.Get", msg["method"])
+		assert.Equal(t, []any{"foo"}, msg["params"])
+	})
+}
+
+var (
+	errDivisionByZero = errors.New("division by zero")
+	errNotFound       = errors.New("not found")
+)
+
+// Service registered on the `net/rpc` server on the other side of the connection
+type RemoteMath struct{}
+
+func (m *RemoteMath) Add(args [2]int, reply *int) error {
+	*reply = args[0] + args[1]
+	return nil
+}
+
+func (m *RemoteMath) Div(args [2]int, reply *int) error {
+	if args[1] == 0 {
+		return errDivisionByZero
+	}
+	*reply = args[0] / args[1]
+	return nil
+}
+
+type RemotePut struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Key-value service registered on the JSON-RPC server on the other side of the connection
+type RemoteKV struct {
+	mu   sync.Mutex
+	dict map[string]string
+}
+
+func (kv *RemoteKV) Put(args RemotePut, reply *bool) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.dict[args.Key] = args.Value
+	*reply = true
+	return nil
+}
+
+func (kv *RemoteKV) Get(key string, reply *string) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	value, ok := kv.dict[key]
+	if !ok {
+		return errNotFound
+	}
+	*reply = value
+	return nil
+}
@@ -0,0 +1,54 @@
+package genserver
+
+import (
+	"errors"
+	"sync"
+)
+
+// Returned for casts rejected by `WithAdaptiveShedding`
+var ErrOverloaded = errors.New("server process is overloaded")
+
+const (
+	// Added to the shed fraction on every cast while the mailbox is above the high water mark
+	shedIncrease = 0.05
+	// The shed fraction is multiplied by it on every cast while the mailbox is below the low water mark
+	shedDecrease = 0.5
+)
+
+// AIMD controller of the fraction of casts to reject. Casts are rejected evenly rather than at random:
+// every cast adds the fraction to a debt, and a cast is rejected when the debt reaches one
+type shedder struct {
+	mu                  sync.Mutex
+	lowWater, highWater float64
+	fraction            float64
+	debt                float64
+}
+
+// Returns nil if shedding is disabled
+func newShedder(lowWater, highWater float64) *shedder {
+	if highWater <= 0 {
+		return nil
+	}
+	return &shedder{lowWater: lowWater, highWater: highWater}
+}
+
+// Reports whether the cast must be rejected given how full the mailbox is, from 0 to 1
+func (s *shedder) shed(fill float64) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case fill >= s.highWater:
+		s.fraction = min(s.fraction+shedIncrease, 1)
+	case fill <= s.lowWater:
+		s.fraction *= shedDecrease
+	}
+	s.debt += s.fraction
+	if s.debt < 1 {
+		return false
+	}
+	s.debt--
+	return true
+}
@@ -0,0 +1,63 @@
+package genserver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveShedding(t *testing.T) {
+	// casts a window of requests and returns how many of them were shed
+	flood := func(s GenServer, n int) int {
+		shed := 0
+		for i := 0; i < n; i++ {
+			if errors.Is(s.CastAck("echo", i), ErrOverloaded) {
+				shed++
+			}
+		}
+		return shed
+	}
+
+	t.Run("should shed more casts as mailbox fills up and fewer as it drains", func(t *testing.T) {
+		// arrange
+		s := Build(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		}, WithAdaptiveShedding(0.25, 0.5))
+		defer s.Close()
+
+		// act
+		var filling []int
+		for i := 0; i < 4; i++ {
+			filling = append(filling, flood(s, 1024))
+		}
+		s.Start()
+		s.WaitIdle(time.Second)
+		drained := flood(s, 1024)
+
+		// assert
+		assert.Zero(t, filling[0])
+		assert.Zero(t, filling[1])
+		assert.Greater(t, filling[2], filling[1])
+		assert.Greater(t, filling[3], filling[2])
+		assert.Less(t, drained, filling[3])
+	})
+
+	t.Run("should never shed blocking calls", func(t *testing.T) {
+		// arrange
+		s := Build(func(genserv GenServer) *EchoServer {
+			return &EchoServer{GenServer: genserv}
+		}, WithAdaptiveShedding(0, 0.001))
+		defer s.Close()
+		flood(s, 100)
+
+		// act
+		errs := make(chan error, 1)
+		go func() { errs <- s.Call("echo", nil, nil) }()
+		s.Start()
+
+		// assert
+		assert.Nil(t, <-errs)
+	})
+}
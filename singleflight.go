@@ -0,0 +1,58 @@
+package genserver
+
+import "sync"
+
+// Duplicate requests waiting for the one that is in flight, see `WithSingleFlight`
+type flight struct {
+	key       string
+	followers []request
+}
+
+type flights struct {
+	mu       sync.Mutex
+	keyFn    func(serviceMethod string, body any) (string, bool)
+	inFlight map[string]*flight
+}
+
+// Returns nil if single-flight is disabled
+func newFlights(keyFn func(serviceMethod string, body any) (string, bool)) *flights {
+	if keyFn == nil {
+		return nil
+	}
+	return &flights{keyFn: keyFn, inFlight: make(map[string]*flight)}
+}
+
+// Returns true if a request with the same key is already in flight, `req` then waits for its reply.
+// Otherwise `req` leads a new flight, if it has a key
+func (f *flights) join(req *request) bool {
+	if f == nil {
+		return false
+	}
+	if _, ok := req.body.(snapshot); ok {
+		return false
+	}
+	key, ok := f.keyFn(req.serviceMethod, req.body)
+	if !ok {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if leader, ok := f.inFlight[key]; ok {
+		leader.followers = append(leader.followers, *req)
+		return true
+	}
+	req.flight = &flight{key: key}
+	f.inFlight[key] = req.flight
+	return false
+}
+
+// Ends the flight and returns the requests waiting for its reply
+func (f *flights) land(fl *flight) []request {
+	if f == nil || fl == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.inFlight, fl.key)
+	return fl.followers
+}
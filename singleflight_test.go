@@ -0,0 +1,95 @@
+package genserver
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSingleFlight(t *testing.T) {
+	getKey := func(serviceMethod string, body any) (string, bool) {
+		if serviceMethod != "get" {
+			return "", false
+		}
+		return body.(string), true
+	}
+
+	t.Run("should handle concurrent duplicate requests once", func(t *testing.T) {
+		// arrange
+		s := Build(func(genserv GenServer) *WarmingServer {
+			return &WarmingServer{GenServer: genserv}
+		}, WithSingleFlight(getKey))
+		defer s.Close()
+
+		// act
+		var wg sync.WaitGroup
+		replies := make([]string, 10)
+		errs := make([]error, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = s.Call("get", "foo", &replies[i])
+			}(i)
+		}
+		time.Sleep(100 * time.Millisecond) // let all the calls reach the mailbox
+		s.Start()
+		wg.Wait()
+
+		// assert
+		assert.Equal(t, int32(1), s.handled.Load())
+		for i := 0; i < 10; i++ {
+			assert.Nil(t, errs[i])
+			assert.Equal(t, "foo is warm", replies[i])
+		}
+	})
+
+	t.Run("should handle request again once the flight has landed", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *WarmingServer {
+			return &WarmingServer{GenServer: genserv}
+		}, WithSingleFlight(getKey))
+		defer s.Close()
+
+		// act
+		err1 := s.Call("get", "foo", nil)
+		err2 := s.Call("get", "foo", nil)
+
+		// assert
+		assert.Nil(t, err1)
+		assert.Nil(t, err2)
+		assert.Equal(t, int32(2), s.handled.Load())
+	})
+
+	t.Run("should not collapse requests without a key", func(t *testing.T) {
+		// arrange
+		s := Build(func(genserv GenServer) *WarmingServer {
+			return &WarmingServer{GenServer: genserv}
+		}, WithSingleFlight(getKey))
+		defer s.Close()
+
+		// act
+		call1 := s.Cast("warm", "foo", nil, nil)
+		call2 := s.Cast("warm", "foo", nil, nil)
+		s.Start()
+		<-call1.Done
+		<-call2.Done
+
+		// assert
+		assert.Equal(t, int32(2), s.handled.Load())
+	})
+}
+
+// Counts how many times it has computed a value
+type WarmingServer struct {
+	GenServer
+	handled atomic.Int32
+}
+
+func (s *WarmingServer) Handle(_ string, _ uint64, body any) (any, error) {
+	s.handled.Add(1)
+	return body.(string) + " is warm", nil
+}
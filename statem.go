@@ -0,0 +1,36 @@
+package genserver
+
+import "errors"
+
+// Returned by `StateMachineBehaviour` for events that are not accepted in the current state
+var ErrUnexpectedEvent = errors.New("unexpected event in the current state")
+
+// Behaviour whose requests are dispatched on the current named state, in the spirit of `gen_statem`
+type StateMachineBehaviour interface {
+	// Returns the state the machine moves to. Return `state` to stay in it
+	HandleEvent(state string, serviceMethod string, body any) (newState string, reply any, err error)
+}
+
+// StateMachine is a `Behaviour` that tracks the current state between requests.
+// Embed it alongside `GenServer` and implement `StateMachineBehaviour` instead of `Handle`
+type StateMachine struct {
+	behaviour StateMachineBehaviour
+	state     string
+}
+
+var _ Behaviour = (*StateMachine)(nil)
+
+func NewStateMachine(initial string, behaviour StateMachineBehaviour) *StateMachine {
+	return &StateMachine{behaviour: behaviour, state: initial}
+}
+
+// Returns the current state. Must be called on the server process, e.g. from `GenServer.Snapshot`
+func (m *StateMachine) State() string {
+	return m.state
+}
+
+func (m *StateMachine) Handle(serviceMethod string, _ uint64, body any) (any, error) {
+	newState, reply, err := m.behaviour.HandleEvent(m.state, serviceMethod, body)
+	m.state = newState
+	return reply, err
+}
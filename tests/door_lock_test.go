@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mapogolions/genserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoorLock(t *testing.T) {
+	t.Run("should unlock with the right code and lock again", func(t *testing.T) {
+		// arrange
+		door := NewDoorLock("1234")
+		defer door.Close()
+
+		// act
+		err1 := door.Unlock("1234")
+		state1, _ := door.CurrentState()
+		err2 := door.Lock()
+		state2, _ := door.CurrentState()
+
+		// assert
+		assert.Nil(t, err1)
+		assert.Equal(t, "unlocked", state1)
+		assert.Nil(t, err2)
+		assert.Equal(t, "locked", state2)
+	})
+
+	t.Run("should stay locked if code is wrong", func(t *testing.T) {
+		// arrange
+		door := NewDoorLock("1234")
+		defer door.Close()
+
+		// act
+		err := door.Unlock("0000")
+		state, _ := door.CurrentState()
+
+		// assert
+		assert.ErrorIs(t, err, ErrWrongCode)
+		assert.Equal(t, "locked", state)
+	})
+
+	t.Run("should reject events in the wrong state", func(t *testing.T) {
+		// arrange
+		door := NewDoorLock("1234")
+		defer door.Close()
+
+		// act
+		errLock := door.Lock()
+		door.Unlock("1234")
+		errUnlock := door.Unlock("1234")
+
+		// assert
+		assert.ErrorIs(t, errLock, genserver.ErrUnexpectedEvent)
+		assert.ErrorIs(t, errUnlock, genserver.ErrUnexpectedEvent)
+	})
+}
+
+var ErrWrongCode = errors.New("wrong code")
+
+func NewDoorLock(code string) *DoorLock {
+	return genserver.Listen(func(genserv genserver.GenServer) *DoorLock {
+		door := &DoorLock{GenServer: genserv, code: code}
+		door.StateMachine = genserver.NewStateMachine("locked", door)
+		return door
+	})
+}
+
+var _ genserver.StateMachineBehaviour = (*DoorLock)(nil)
+
+type DoorLock struct {
+	genserver.GenServer
+	*genserver.StateMachine
+	code string
+}
+
+func (d *DoorLock) Unlock(code string) error {
+	return d.Call("unlock", code, nil)
+}
+
+func (d *DoorLock) Lock() error {
+	return d.Call("lock", nil, nil)
+}
+
+func (d *DoorLock) CurrentState() (string, error) {
+	var state string
+	err := d.Snapshot(func(genserver.Behaviour) { state = d.State() })
+	return state, err
+}
+
+func (d *DoorLock) HandleEvent(state string, serviceMethod string, body any) (string, any, error) {
+	switch state {
+	case "locked":
+		if serviceMethod == "unlock" {
+			if body.(string) != d.code {
+				return state, nil, ErrWrongCode
+			}
+			return "unlocked", nil, nil
+		}
+	case "unlocked":
+		if serviceMethod == "lock" {
+			return "locked", nil, nil
+		}
+	}
+	return state, nil, genserver.ErrUnexpectedEvent
+}
@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKVClient(t *testing.T) {
+	t.Run("should put and get value", func(t *testing.T) {
+		// arrange
+		client := NewKVClient[string, int](NewDict[string, int]())
+		defer client.Close()
+
+		// act
+		err := client.Put("one", 1)
+		v, err2 := client.Get("one")
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, err2)
+		assert.Equal(t, 1, v)
+	})
+
+	t.Run("should return error if key already exists", func(t *testing.T) {
+		// arrange
+		client := NewKVClient(NewDict(KeyValuePair[string, int]{"one", 1}))
+		defer client.Close()
+
+		// act
+		err := client.Put("one", -1)
+
+		// assert
+		assert.ErrorIs(t, err, ErrKeyExists)
+	})
+
+	t.Run("should return not found error", func(t *testing.T) {
+		// arrange
+		client := NewKVClient[string, int](NewDict[string, int]())
+		defer client.Close()
+
+		// act
+		v, err := client.Get("one")
+
+		// assert
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+		assert.Equal(t, 0, v)
+	})
+
+	t.Run("should delete key", func(t *testing.T) {
+		// arrange
+		client := NewKVClient(NewDict(KeyValuePair[string, int]{"one", -1}))
+		defer client.Close()
+
+		// act
+		v, err := client.Delete("one")
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, -1, v)
+	})
+
+	t.Run("should return error if deleted key does not exist", func(t *testing.T) {
+		// arrange
+		client := NewKVClient[string, int](NewDict[string, int]())
+		defer client.Close()
+
+		// act
+		v, err := client.Delete("one")
+
+		// assert
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+		assert.Equal(t, 0, v)
+	})
+}
+
+// Typed API on top of the string-based `Call`, so callers can't mistype a service method or an argument
+type KVClient[K comparable, V any] struct {
+	server *kvStoreServer[K, V]
+}
+
+func NewKVClient[K comparable, V any](store KVStore[K, V]) *KVClient[K, V] {
+	return &KVClient[K, V]{server: NewKVStoreServer(store)}
+}
+
+func (c *KVClient[K, V]) Get(key K) (V, error) {
+	var v V
+	err := c.server.Call("get", key, &v)
+	return v, err
+}
+
+func (c *KVClient[K, V]) Put(key K, value V) error {
+	return c.server.Call("put", KeyValuePair[K, V]{key, value}, nil)
+}
+
+func (c *KVClient[K, V]) Delete(key K) (V, error) {
+	var v V
+	err := c.server.Call("delete", key, &v)
+	return v, err
+}
+
+func (c *KVClient[K, V]) Close() error {
+	return c.server.Close()
+}
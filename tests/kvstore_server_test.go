@@ -3,11 +3,14 @@ package tests
 import (
 	"errors"
 	"net/rpc"
+	"reflect"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/mapogolions/genserver"
+	"github.com/mapogolions/genserver/genservertest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -43,6 +46,41 @@ func TestKVStoreServer(t *testing.T) {
 		wg.Wait()
 	})
 
+	t.Run("should scan store page by page", func(t *testing.T) {
+		// arrange
+		dict := NewDict[string, int]()
+		for i := 0; i < 100; i++ {
+			dict.Put(strconv.Itoa(i), i)
+		}
+		store := NewKVStoreServer[string, int](dict)
+		defer store.Close()
+
+		// act
+		seen := make(map[string]int)
+		var pages int
+		var cursor Cursor
+		for {
+			var page ScanPage[string, int]
+			err := store.Call("scan", ScanRequest{Cursor: cursor, Limit: 10}, &page)
+			assert.Nil(t, err)
+			for _, kvp := range page.Items {
+				seen[kvp.Key]++
+			}
+			pages++
+			store.Call("put", KeyValuePair[string, int]{"new" + strconv.Itoa(pages), -1}, nil) // interleaved writes
+			if page.Done {
+				break
+			}
+			cursor = page.Cursor
+		}
+
+		// assert
+		for i := 0; i < 100; i++ {
+			assert.Equal(t, 1, seen[strconv.Itoa(i)])
+		}
+		assert.GreaterOrEqual(t, pages, 10)
+	})
+
 	t.Run("should return shutdown error when trying to make call on closed server", func(t *testing.T) {
 		// arrange
 		dict := NewDict[string, int]()
@@ -59,6 +97,23 @@ func TestKVStoreServer(t *testing.T) {
 		assert.ErrorIs(t, call.Error, rpc.ErrShutdown)
 	})
 
+	t.Run("should keep handling requests after key of wrong type", func(t *testing.T) {
+		// arrange
+		store := NewKVStoreServer(NewDict(KeyValuePair[string, int]{"one", 1}))
+		defer store.Close()
+
+		// act
+		err := store.Call("get", 1, nil)
+		var v int
+		err2 := store.Call("get", "one", &v)
+
+		// assert
+		var argErr *genserver.ArgTypeError
+		assert.ErrorAs(t, err, &argErr)
+		assert.Nil(t, err2)
+		assert.Equal(t, 1, v)
+	})
+
 	t.Run("delete key should return error if key does not exists", func(t *testing.T) {
 		// arrange
 		dict := NewDict[string, int]()
@@ -71,10 +126,52 @@ func TestKVStoreServer(t *testing.T) {
 		<-call.Done
 
 		// assert
-		assert.NotNil(t, call.Error)
+		assert.ErrorIs(t, call.Error, ErrKeyNotFound)
 		assert.Equal(t, 0, reply)
 	})
 
+	t.Run("should report error of handler", func(t *testing.T) {
+		// arrange
+		type handlerError struct {
+			serviceMethod string
+			err           error
+		}
+		errs := make(chan handlerError, 1)
+		store := NewKVStoreServer[string, int](NewDict[string, int](), genserver.WithOnHandlerError(
+			func(serviceMethod string, _ uint64, err error) {
+				errs <- handlerError{serviceMethod, err}
+			},
+		))
+		defer store.Close()
+
+		// act
+		err := store.Call("delete", "one", nil)
+		err2 := store.Call("put", KeyValuePair[string, int]{"one", 1}, nil)
+
+		// assert
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+		assert.Nil(t, err2)
+		reported := <-errs
+		assert.Equal(t, "delete", reported.serviceMethod)
+		assert.ErrorIs(t, reported.err, ErrKeyNotFound)
+		assert.Empty(t, errs)
+	})
+
+	t.Run("should preserve identity of errors across server process boundary", func(t *testing.T) {
+		// arrange
+		dict := NewDict(KeyValuePair[string, int]{"one", -1})
+		store := NewKVStoreServer[string, int](dict)
+		defer store.Close()
+
+		// act
+		deleteErr := store.Call("delete", "two", nil)
+		putErr := store.Call("put", KeyValuePair[string, int]{"one", 1}, nil)
+
+		// assert
+		assert.True(t, errors.Is(deleteErr, ErrKeyNotFound))
+		assert.True(t, errors.Is(putErr, ErrKeyExists))
+	})
+
 	t.Run("should delete key from store if it exists", func(t *testing.T) {
 		// arrange
 		dict := NewDict[string, int](KeyValuePair[string, int]{"one", -1})
@@ -92,6 +189,28 @@ func TestKVStoreServer(t *testing.T) {
 		assert.Equal(t, -1, genserver.Reply[int](call))
 	})
 
+	t.Run("should pop key and report whether it existed", func(t *testing.T) {
+		// arrange
+		dict := NewDict[string, int](KeyValuePair[string, int]{"one", -1})
+		store := NewKVStoreServer[string, int](dict)
+		defer store.Close()
+
+		// act
+		var reply genserver.Tuple2[int, bool]
+		call := store.Cast("pop", "one", &reply, nil)
+		<-call.Done
+		missing := store.Cast("pop", "two", &genserver.Tuple2[int, bool]{}, nil)
+		<-missing.Done
+
+		// assert
+		assert.Nil(t, call.Error)
+		v, found := genserver.ReplyTuple2[int, bool](call)
+		assert.Equal(t, -1, v)
+		assert.True(t, found)
+		_, found = genserver.ReplyTuple2[int, bool](missing)
+		assert.False(t, found)
+	})
+
 	t.Run("should put key value pair into store", func(t *testing.T) {
 		// arrange
 		dict := NewDict[string, int]()
@@ -187,10 +306,114 @@ func TestKVStoreServer(t *testing.T) {
 	})
 }
 
+func TestKVStoreBehaviour(t *testing.T) {
+	newDriver := func(pairs ...KeyValuePair[string, int]) *genservertest.Driver {
+		return genservertest.NewDriver(newKVStoreServer[string, int](nil, NewDict(pairs...)))
+	}
+
+	t.Run("should get value by key", func(t *testing.T) {
+		// arrange
+		driver := newDriver(KeyValuePair[string, int]{"one", 1})
+
+		// act
+		v, err := driver.Send("get", "one")
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 1, v)
+	})
+
+	t.Run("should put key value pair", func(t *testing.T) {
+		// arrange
+		driver := newDriver()
+
+		// act
+		_, err := driver.Send("put", KeyValuePair[string, int]{"one", 1})
+		v, err2 := driver.Send("get", "one")
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, err2)
+		assert.Equal(t, 1, v)
+	})
+
+	t.Run("should reject key of wrong type", func(t *testing.T) {
+		// arrange
+		driver := newDriver(KeyValuePair[string, int]{"one", 1})
+
+		// act
+		_, err := driver.Send("get", 1)
+
+		// assert
+		var argErr *genserver.ArgTypeError
+		assert.ErrorAs(t, err, &argErr)
+		assert.Equal(t, reflect.TypeOf(""), argErr.Expected)
+		assert.Equal(t, reflect.TypeOf(0), argErr.Actual)
+	})
+
+	t.Run("should return invalid arguments error", func(t *testing.T) {
+		// arrange
+		driver := newDriver()
+
+		// act
+		_, err := driver.Send("put", "one")
+
+		// assert
+		assert.EqualError(t, err, "invalid arguments")
+	})
+
+	t.Run("should return error if key does not exist", func(t *testing.T) {
+		// arrange
+		driver := newDriver()
+
+		// act
+		_, err := driver.Send("delete", "one")
+
+		// assert
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+	})
+
+	t.Run("should return unknown method error", func(t *testing.T) {
+		// arrange
+		driver := newDriver()
+
+		// act
+		_, err := driver.Send("truncate", nil)
+
+		// assert
+		assert.ErrorIs(t, err, genserver.ErrUnknownMethod)
+	})
+}
+
+var (
+	ErrKeyExists   = errors.New("key already exists")
+	ErrKeyNotFound = errors.New("key does not exist")
+)
+
 type KVStore[K comparable, V any] interface {
 	Get(key K) (V, error)
 	Put(key K, v V) error
 	Delete(key K) (V, error)
+	// Returns up to `limit` pairs starting at `cursor`, the cursor of the next page, and whether the scan is complete
+	Scan(cursor Cursor, limit int) ([]KeyValuePair[K, V], Cursor, bool)
+}
+
+// Position of a scan in the store, the zero value starts from the beginning.
+// It stays valid while other requests are handled between pages
+type Cursor struct {
+	next int
+}
+
+type ScanRequest struct {
+	Cursor Cursor
+	Limit  int
+}
+
+// Page of a scan. Pass `Cursor` back to get the next page, unless `Done` is set
+type ScanPage[K, V any] struct {
+	Items  []KeyValuePair[K, V]
+	Cursor Cursor
+	Done   bool
 }
 
 type KeyValuePair[K, V any] struct {
@@ -204,6 +427,7 @@ var _ genserver.Behaviour = (*kvStoreServer[string, int])(nil)
 // and constantly listens for incoming requests.
 type kvStoreServer[K comparable, V any] struct {
 	genserver.GenServer
+	*genserver.Dispatcher
 	store KVStore[K, V]
 }
 
@@ -215,51 +439,81 @@ type kvStoreServer[K comparable, V any] struct {
 // }
 
 // version 2
-func NewKVStoreServer[K comparable, V any](store KVStore[K, V]) *kvStoreServer[K, V] {
+func NewKVStoreServer[K comparable, V any](store KVStore[K, V], opts ...genserver.Option) *kvStoreServer[K, V] {
 	return genserver.Listen(func(genserv genserver.GenServer) *kvStoreServer[K, V] {
-		return &kvStoreServer[K, V]{store: store, GenServer: genserv}
-	})
+		return newKVStoreServer(genserv, store)
+	}, opts...)
 }
 
-func (s *kvStoreServer[K, V]) Handle(serviceMethod string, _ uint64, body any) (any, error) {
-	var v any
-	var err error
-	switch serviceMethod {
-	case "get":
-		v, err = s.store.Get(body.(K))
-	case "delete":
-		v, err = s.store.Delete(body.(K))
-	case "put":
-		kvp, ok := body.(KeyValuePair[K, V])
-		if ok {
-			err = s.store.Put(kvp.Key, kvp.Value)
-		} else {
-			err = errors.New("invalid arguments")
-		}
-	default:
-		panic("not implemented")
+func newKVStoreServer[K comparable, V any](genserv genserver.GenServer, store KVStore[K, V]) *kvStoreServer[K, V] {
+	s := &kvStoreServer[K, V]{store: store, GenServer: genserv, Dispatcher: genserver.NewDispatcher()}
+	s.Register("get", s.get)
+	s.Register("delete", s.delete)
+	s.Register("pop", s.pop)
+	s.Register("scan", s.scan)
+	s.Register("put", s.put)
+	return s
+}
+
+func (s *kvStoreServer[K, V]) get(body any) (any, error) {
+	key, err := genserver.Arg[K](body)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.Get(key)
+}
+
+func (s *kvStoreServer[K, V]) delete(body any) (any, error) {
+	key, err := genserver.Arg[K](body)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.Delete(key)
+}
+
+func (s *kvStoreServer[K, V]) pop(body any) (any, error) {
+	key, err := genserver.Arg[K](body)
+	if err != nil {
+		return nil, err
+	}
+	deleted, deleteErr := s.store.Delete(key)
+	return genserver.Tuple2[V, bool]{A: deleted, B: deleteErr == nil}, nil
+}
+
+func (s *kvStoreServer[K, V]) scan(body any) (any, error) {
+	req, err := genserver.Arg[ScanRequest](body)
+	if err != nil {
+		return nil, err
+	}
+	items, cursor, done := s.store.Scan(req.Cursor, req.Limit)
+	return ScanPage[K, V]{Items: items, Cursor: cursor, Done: done}, nil
+}
+
+func (s *kvStoreServer[K, V]) put(body any) (any, error) {
+	kvp, ok := body.(KeyValuePair[K, V])
+	if !ok {
+		return nil, errors.New("invalid arguments")
 	}
-	return v, err
+	return nil, s.store.Put(kvp.Key, kvp.Value)
 }
 
 type dict[K comparable, V any] struct {
-	data map[K]V
+	data  map[K]V
+	order *[]K // keys in insertion order, so a scan cursor is an index that new keys don't shift
 }
 
 func NewDict[K comparable, V any](pairs ...KeyValuePair[K, V]) dict[K, V] {
-	data := make(map[K]V)
-	if len(pairs) > 0 {
-		for _, pair := range pairs {
-			data[pair.Key] = pair.Value
-		}
+	d := dict[K, V]{data: make(map[K]V), order: new([]K)}
+	for _, pair := range pairs {
+		d.Put(pair.Key, pair.Value)
 	}
-	return dict[K, V]{data: data}
+	return d
 }
 
 func (d dict[K, V]) Get(key K) (V, error) {
 	v, ok := d.data[key]
 	if !ok {
-		return v, errors.New("not found")
+		return v, ErrKeyNotFound
 	}
 	return v, nil
 }
@@ -267,16 +521,29 @@ func (d dict[K, V]) Get(key K) (V, error) {
 func (d dict[K, V]) Put(key K, value V) error {
 	_, ok := d.data[key]
 	if ok {
-		return errors.New("key already exists")
+		return ErrKeyExists
 	}
 	d.data[key] = value
+	*d.order = append(*d.order, key)
 	return nil
 }
 
 func (d dict[K, V]) Delete(key K) (V, error) {
 	v, ok := d.data[key]
 	if !ok {
-		return v, errors.New("key does not exist")
+		return v, ErrKeyNotFound
 	}
 	return v, nil
 }
+
+func (d dict[K, V]) Scan(cursor Cursor, limit int) ([]KeyValuePair[K, V], Cursor, bool) {
+	keys := *d.order
+	var items []KeyValuePair[K, V]
+	i := cursor.next
+	for ; i < len(keys) && len(items) < limit; i++ {
+		if v, ok := d.data[keys[i]]; ok { // deleted keys are skipped
+			items = append(items, KeyValuePair[K, V]{keys[i], v})
+		}
+	}
+	return items, Cursor{next: i}, i >= len(keys)
+}
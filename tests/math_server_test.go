@@ -1,9 +1,11 @@
 package tests
 
 import (
-	"errors"
+	"fmt"
 	"net/rpc"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/mapogolions/genserver"
 	"github.com/stretchr/testify/assert"
@@ -23,6 +25,55 @@ func TestMathServer(t *testing.T) {
 		assert.ErrorIs(t, call.Error, ErrUnsupportedMathOperation)
 	})
 
+	t.Run("should add and return new value in a single round trip", func(t *testing.T) {
+		// arrange
+		s := NewMathServer()
+		defer s.Close()
+
+		// act
+		v, err := s.AddAndGet(2)
+		var handled int
+		s.Snapshot(func(genserver.Behaviour) { handled = s.handled })
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 2, v)
+		assert.Equal(t, 1, handled)
+	})
+
+	t.Run("should map reply", func(t *testing.T) {
+		// arrange
+		s := NewMathServer()
+		defer s.Close()
+		format := func(v int) string { return fmt.Sprintf("value: %d", v) }
+		s.Add(2)
+
+		// act
+		v, err := genserver.CallMap(s, "value", nil, format)
+		_, err2 := genserver.CallMap(s, "%", 2, format)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "value: 2", v)
+		assert.ErrorIs(t, err2, ErrUnsupportedMathOperation)
+	})
+
+	t.Run("should continue to handle requests after error", func(t *testing.T) {
+		// arrange
+		s := NewMathServer()
+		defer s.Close()
+
+		// act
+		call := s.Cast("%", 2, nil, nil)
+		<-call.Done
+		v, err := s.Value()
+
+		// assert
+		assert.ErrorIs(t, call.Error, ErrUnsupportedMathOperation)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, v)
+	})
+
 	t.Run("should add", func(t *testing.T) {
 		// arrange
 		s := NewMathServer()
@@ -37,21 +88,226 @@ func TestMathServer(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, 2, v)
 	})
+
+	t.Run("should handle cast and call from one goroutine in order", func(t *testing.T) {
+		// arrange
+		s := NewMathServer()
+		defer s.Close()
+
+		// act + assert
+		for i := 1; i <= 1000; i++ {
+			s.Add(1)
+			v, err := s.Value()
+			assert.Nil(t, err)
+			if !assert.Equal(t, i, v) {
+				return
+			}
+		}
+	})
+
+	t.Run("should reach final state after burst of casts", func(t *testing.T) {
+		// arrange
+		s := NewMathServer()
+		defer s.Close()
+
+		// act
+		for i := 0; i < 100; i++ {
+			s.Add(1)
+		}
+		err := s.WaitIdle(time.Second)
+		var value int
+		s.Snapshot(func(genserver.Behaviour) { value = s.value })
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 100, value)
+	})
+
+	t.Run("should reflect updates in replica view after ttl", func(t *testing.T) {
+		// arrange
+		s := NewMathServer()
+		defer s.Close()
+		value := genserver.ReplicaView[int](s, "value", 50*time.Millisecond)
+		s.Add(2)
+		before := value()
+
+		// act
+		s.Add(3)
+		cached := value()
+		time.Sleep(60 * time.Millisecond)
+		after := value()
+
+		// assert
+		assert.Equal(t, 2, before)
+		assert.Equal(t, 2, cached)
+		assert.Equal(t, 5, after)
+	})
+
+	t.Run("should expose behaviour for inspection", func(t *testing.T) {
+		// arrange
+		s := NewMathServer()
+		defer s.Close()
+
+		// act
+		s.Add(2)
+		s.Add(3)
+		var value int
+		err := s.Snapshot(func(b genserver.Behaviour) {
+			value = b.(*MathServer).value
+		})
+
+		// assert
+		assert.Same(t, s, s.Behaviour())
+		assert.Nil(t, err)
+		assert.Equal(t, 5, value)
+	})
+
+	t.Run("should continue from the state of the primary after takeover", func(t *testing.T) {
+		// arrange
+		primary := NewMathServer()
+		defer primary.Close()
+		standby := NewMathServer()
+		defer standby.Close()
+		primary.Add(2)
+		primary.Add(3)
+
+		// act
+		err := genserver.Takeover(standby, primary)
+		standby.Add(1)
+		v, err2 := standby.Value()
+
+		// assert
+		assert.Nil(t, err)
+		assert.Nil(t, err2)
+		assert.Equal(t, 6, v)
+	})
+
+	t.Run("should handle requests only after start", func(t *testing.T) {
+		// arrange
+		s := BuildMathServer()
+		defer s.Close()
+		registry := map[string]genserver.GenServer{"math": s}
+
+		// act + assert
+		call := s.Add(2)
+		select {
+		case <-call.Done:
+			t.Fatal("request handled before start")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		assert.Nil(t, registry["math"].Start())
+		<-call.Done
+		v, err := s.Value()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, v)
+	})
+
+	t.Run("should describe supported methods", func(t *testing.T) {
+		// arrange
+		s := NewMathServer()
+		defer s.Close()
+		intType := reflect.TypeOf(0)
+
+		// act
+		methods, err := genserver.DescribeMethods(s)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, []genserver.MethodInfo{
+			{Name: "+", Arg: intType},
+			{Name: "-", Arg: intType},
+			{Name: "*", Arg: intType},
+			{Name: "value", Reply: intType},
+		}, methods)
+	})
+
+	t.Run("should validate argument before calling", func(t *testing.T) {
+		// arrange
+		s := NewMathServer()
+		defer s.Close()
+		methods, _ := genserver.DescribeMethods(s)
+		add := methods[0]
+
+		// act + assert
+		assert.False(t, add.Accepts("two"))
+		assert.True(t, add.Accepts(2))
+		<-s.Cast(add.Name, 2, nil, nil).Done
+		v, err := s.Value()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, v)
+	})
+
+	t.Run("should return error if already started", func(t *testing.T) {
+		// arrange
+		s := NewMathServer()
+		defer s.Close()
+
+		// act
+		err := s.Start()
+
+		// assert
+		assert.ErrorIs(t, err, genserver.ErrAlreadyStarted)
+	})
+
+	t.Run("should fail fast if behaviour is nil", func(t *testing.T) {
+		// arrange
+		newNil := func(genserver.GenServer) *MathServer { return nil }
+
+		// act
+		_, err := genserver.ListenE(newNil)
+
+		// assert
+		assert.ErrorIs(t, err, genserver.ErrNilBehaviour)
+		assert.PanicsWithValue(t, genserver.ErrNilBehaviour, func() { genserver.Listen(newNil) })
+		assert.PanicsWithValue(t, genserver.ErrNilBehaviour, func() { genserver.Build(newNil) })
+		assert.PanicsWithValue(t, genserver.ErrNilBehaviour, func() { genserver.NewGenServer().Listen(nil) })
+	})
 }
 
-var ErrUnsupportedMathOperation = errors.New("unsupported math operation")
+// Operations the math server doesn't register are rejected by its `Dispatcher`
+var ErrUnsupportedMathOperation = genserver.ErrUnknownMethod
 
 func NewMathServer() *MathServer {
-	return genserver.Listen(func(genserv genserver.GenServer) *MathServer {
-		return &MathServer{GenServer: genserv}
+	return genserver.Listen(newMathServer)
+}
+
+func BuildMathServer() *MathServer {
+	return genserver.Build(newMathServer)
+}
+
+func newMathServer(genserv genserver.GenServer) *MathServer {
+	s := &MathServer{GenServer: genserv, Dispatcher: genserver.NewDispatcher()}
+	s.Register("+", func(body any) (any, error) {
+		s.value += body.(int)
+		return nil, nil
+	})
+	s.Register("add_and_get", func(body any) (any, error) {
+		s.value += body.(int)
+		return s.value, nil
 	})
+	s.Register("-", func(body any) (any, error) {
+		s.value -= body.(int)
+		return nil, nil
+	})
+	s.Register("*", func(body any) (any, error) {
+		s.value *= body.(int)
+		return nil, nil
+	})
+	s.Register("value", func(any) (any, error) {
+		return s.value, nil
+	})
+	return s
 }
 
-var _ genserver.Behaviour = (*MathServer)(nil)
+var _ genserver.HandoffBehaviour = (*MathServer)(nil)
+var _ genserver.Describable = (*MathServer)(nil)
 
 type MathServer struct {
 	genserver.GenServer
-	value int
+	*genserver.Dispatcher
+	value   int
+	handled int
 }
 
 func (s *MathServer) Add(v int) *rpc.Call {
@@ -66,26 +322,37 @@ func (s *MathServer) Mul(v int) *rpc.Call {
 	return s.Cast("*", v, nil, nil)
 }
 
+func (s *MathServer) AddAndGet(v int) (int, error) {
+	return genserver.CallReturningState[int](s, "add_and_get", v)
+}
+
 func (s *MathServer) Value() (int, error) {
 	var v int
 	err := s.Call("value", nil, &v)
 	return v, err
 }
 
-func (s *MathServer) Handle(serviceMethod string, seq uint64, body any) (any, error) {
-	var v any
-	var err error
-	switch serviceMethod {
-	case "+":
-		s.value += body.(int)
-	case "-":
-		s.value -= body.(int)
-	case "*":
-		s.value *= body.(int)
-	case "value":
-		v = s.value
-	default:
-		err = ErrUnsupportedMathOperation
+func (s *MathServer) ExportState() any {
+	return s.value
+}
+
+func (s *MathServer) ImportState(state any) error {
+	s.value = state.(int)
+	return nil
+}
+
+func (s *MathServer) DescribeMethods() []genserver.MethodInfo {
+	intType := reflect.TypeOf(0)
+	return []genserver.MethodInfo{
+		{Name: "+", Arg: intType},
+		{Name: "-", Arg: intType},
+		{Name: "*", Arg: intType},
+		{Name: "value", Reply: intType},
 	}
-	return v, err
+}
+
+// Counts every request, the dispatching itself is left to the embedded `Dispatcher`
+func (s *MathServer) Handle(serviceMethod string, seq uint64, body any) (any, error) {
+	s.handled++
+	return s.Dispatcher.Handle(serviceMethod, seq, body)
 }
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"net/rpc"
+	"reflect"
+	"testing"
+
+	"github.com/mapogolions/genserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedMathServer(t *testing.T) {
+	t.Run("should add", func(t *testing.T) {
+		// arrange
+		s := NewTypedMathServer()
+		defer s.Close()
+
+		// act
+		call := s.Add(2)
+		<-call.Done
+		v, err := s.Value()
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 2, v)
+	})
+
+	t.Run("should return typed reply of non-blocking request", func(t *testing.T) {
+		// arrange
+		s := NewTypedMathServer()
+		defer s.Close()
+
+		// act
+		s.Add(3)
+		var reply int
+		call := s.Cast("value", 0, &reply, nil)
+		<-call.Done
+
+		// assert
+		assert.Nil(t, call.Error)
+		assert.Equal(t, 3, reply)
+	})
+
+	t.Run("should return unsupported math operation error", func(t *testing.T) {
+		// arrange
+		s := NewTypedMathServer()
+		defer s.Close()
+
+		// act
+		_, err := s.Call("%", 2)
+
+		// assert
+		assert.ErrorIs(t, err, ErrUnsupportedMathOperation)
+	})
+
+	t.Run("should accept and return only statically typed values", func(t *testing.T) {
+		// `s.Call("+", "two")` does not compile, the signature pins both request and reply types
+		call, ok := reflect.TypeOf((*genserver.TypedGenServer[int, int])(nil)).Elem().MethodByName("Call")
+
+		assert.True(t, ok)
+		assert.Equal(t, reflect.TypeOf(0), call.Type.In(1))
+		assert.Equal(t, reflect.TypeOf(0), call.Type.Out(0))
+	})
+}
+
+func NewTypedMathServer() *TypedMathServer {
+	return genserver.ListenTyped(func(genserv genserver.TypedGenServer[int, int]) *TypedMathServer {
+		return &TypedMathServer{TypedGenServer: genserv}
+	})
+}
+
+var _ genserver.TypedBehaviour[int, int] = (*TypedMathServer)(nil)
+
+type TypedMathServer struct {
+	genserver.TypedGenServer[int, int]
+	value int
+}
+
+func (s *TypedMathServer) Add(v int) *rpc.Call {
+	return s.Cast("+", v, nil, nil)
+}
+
+func (s *TypedMathServer) Sub(v int) *rpc.Call {
+	return s.Cast("-", v, nil, nil)
+}
+
+func (s *TypedMathServer) Mul(v int) *rpc.Call {
+	return s.Cast("*", v, nil, nil)
+}
+
+func (s *TypedMathServer) Value() (int, error) {
+	return s.Call("value", 0)
+}
+
+func (s *TypedMathServer) Handle(serviceMethod string, _ uint64, v int) (int, error) {
+	switch serviceMethod {
+	case "+":
+		s.value += v
+	case "-":
+		s.value -= v
+	case "*":
+		s.value *= v
+	case "value":
+		return s.value, nil
+	default:
+		return 0, ErrUnsupportedMathOperation
+	}
+	return 0, nil
+}
@@ -0,0 +1,94 @@
+package genserver
+
+import (
+	"errors"
+	"log"
+	"net/rpc"
+	"sync/atomic"
+	"time"
+)
+
+// Optional contract. If implemented and `WithTick` is set, `Tick` is called by the server process
+// on every interval, serialized with requests
+type TickBehaviour interface {
+	Behaviour
+	Tick()
+}
+
+// Internal request that makes the server process call `TickBehaviour.Tick`
+type tick struct{}
+
+// Puts a tick into the mailbox on every interval until the server process terminates.
+// A tick is skipped if the previous one is still in the mailbox or the mailbox is full
+func (c *genServerCodec) tick() {
+	for {
+		fired, stop := timer(c.options.clock, c.options.tickInterval)
+		select {
+		case <-c.done:
+			stop()
+			return
+		case <-fired:
+		}
+		if !c.tickPending.CompareAndSwap(false, true) {
+			continue
+		}
+		c.outstanding.Add(1)
+		ok, err := c.requests.tryPut(request{body: tick{}})
+		if !ok {
+			c.outstanding.Add(-1)
+			c.tickPending.Store(false)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *genServer) SendAfter(d time.Duration, serviceMethod string, args any) func() bool {
+	return sendAfter(s, s.codec.options.clock, d, serviceMethod, args)
+}
+
+func sendAfter(s GenServer, clock Clock, d time.Duration, serviceMethod string, args any) func() bool {
+	var fired atomic.Bool
+	cancel := make(chan struct{})
+	go func() {
+		due, stop := timer(clock, d)
+		select {
+		case <-s.Done():
+			stop()
+			return
+		case <-cancel:
+			stop()
+			return
+		case <-due:
+		}
+		if !fired.CompareAndSwap(false, true) {
+			return
+		}
+		// nobody waits for the reply, so an error of the behaviour is only logged, as for a tick
+		call := <-s.Cast(serviceMethod, args, nil, make(chan *rpc.Call, 1)).Done
+		if call.Error != nil && !errors.Is(call.Error, rpc.ErrShutdown) && !errors.Is(call.Error, ErrServerClosing) {
+			log.Print(call.Error)
+		}
+	}()
+	return func() bool {
+		if !fired.CompareAndSwap(false, true) {
+			return false
+		}
+		close(cancel)
+		return true
+	}
+}
+
+func (c *genServerCodec) handleTick(behaviour Behaviour) {
+	c.tickPending.Store(false)
+	b, ok := behaviour.(TickBehaviour)
+	if !ok {
+		return
+	}
+	var err error
+	tryCatch(b.Tick, &err)
+	if err != nil {
+		log.Print(err)
+	}
+}
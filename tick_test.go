@@ -0,0 +1,163 @@
+package genserver
+
+import (
+	"fmt"
+	"net/rpc"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTick(t *testing.T) {
+	t.Run("should tick periodically serialized with requests", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *TickServer {
+			return &TickServer{GenServer: genserv}
+		}, WithTick(20*time.Millisecond))
+		defer s.Close()
+
+		// act
+		done := make(chan *rpc.Call, 10)
+		for i := 0; i < 10; i++ {
+			s.Cast("inc", nil, nil, done)
+			time.Sleep(15 * time.Millisecond)
+		}
+		for i := 0; i < 10; i++ {
+			<-done
+		}
+		var ticks, incs int
+		err := s.Snapshot(func(Behaviour) { ticks, incs = s.ticks, s.incs })
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 10, incs)
+		assert.GreaterOrEqual(t, ticks, 3)
+	})
+
+	t.Run("should not tick if interval is zero", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *TickServer {
+			return &TickServer{GenServer: genserv}
+		})
+		defer s.Close()
+
+		// act
+		time.Sleep(50 * time.Millisecond)
+		var ticks int
+		s.Snapshot(func(Behaviour) { ticks = s.ticks })
+
+		// assert
+		assert.Zero(t, ticks)
+	})
+}
+
+func TestSendAfter(t *testing.T) {
+	t.Run("should expire lock with delayed self-message", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *LockServer {
+			return &LockServer{GenServer: genserv, ttl: 20 * time.Millisecond}
+		})
+		defer s.Close()
+
+		// act
+		var acquired, held bool
+		err := s.Call("acquire", "foo", &acquired)
+		s.Call("held", "foo", &held)
+		time.Sleep(60 * time.Millisecond)
+		var expired bool
+		s.Call("held", "foo", &expired)
+
+		// assert
+		assert.Nil(t, err)
+		assert.True(t, acquired)
+		assert.True(t, held)
+		assert.False(t, expired)
+	})
+
+	t.Run("should not send cancelled message", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *TickServer {
+			return &TickServer{GenServer: genserv}
+		})
+		defer s.Close()
+
+		// act
+		cancel := s.SendAfter(20*time.Millisecond, "inc", nil)
+		cancelled := cancel()
+		time.Sleep(50 * time.Millisecond)
+		var incs int
+		s.Snapshot(func(Behaviour) { incs = s.incs })
+
+		// assert
+		assert.True(t, cancelled)
+		assert.False(t, cancel())
+		assert.Zero(t, incs)
+	})
+
+	t.Run("should not cancel sent message", func(t *testing.T) {
+		// arrange
+		s := Listen(func(genserv GenServer) *TickServer {
+			return &TickServer{GenServer: genserv}
+		})
+		defer s.Close()
+
+		// act
+		cancel := s.SendAfter(time.Millisecond, "inc", nil)
+		time.Sleep(30 * time.Millisecond)
+		var incs int
+		s.Snapshot(func(Behaviour) { incs = s.incs })
+
+		// assert
+		assert.False(t, cancel())
+		assert.Equal(t, 1, incs)
+	})
+}
+
+// Holds locks that expire after `ttl`, the expiry is a delayed message to itself
+type LockServer struct {
+	GenServer
+	ttl   time.Duration
+	locks map[string]struct{}
+}
+
+func (s *LockServer) Handle(serviceMethod string, _ uint64, body any) (any, error) {
+	if s.locks == nil {
+		s.locks = make(map[string]struct{})
+	}
+	key := body.(string)
+	_, held := s.locks[key]
+	switch serviceMethod {
+	case "acquire":
+		if held {
+			return false, nil
+		}
+		s.locks[key] = struct{}{}
+		s.SendAfter(s.ttl, "expire", key)
+		return true, nil
+	case "expire":
+		delete(s.locks, key)
+		return nil, nil
+	case "held":
+		return held, nil
+	}
+	return nil, fmt.Errorf("unknown method %q", serviceMethod)
+}
+
+var _ TickBehaviour = (*TickServer)(nil)
+
+// Counts ticks and requests without any synchronization, relying on the server process
+type TickServer struct {
+	GenServer
+	ticks int
+	incs  int
+}
+
+func (s *TickServer) Tick() {
+	s.ticks++
+}
+
+func (s *TickServer) Handle(_ string, _ uint64, _ any) (any, error) {
+	s.incs++
+	return nil, nil
+}
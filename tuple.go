@@ -0,0 +1,15 @@
+package genserver
+
+import "net/rpc"
+
+// Lets a handler return two values as a single reply
+type Tuple2[A, B any] struct {
+	A A
+	B B
+}
+
+// Unpacks the `Tuple2` reply of a completed call
+func ReplyTuple2[A, B any](call *rpc.Call) (A, B) {
+	t := Reply[Tuple2[A, B]](call)
+	return t.A, t.B
+}
@@ -0,0 +1,89 @@
+package genserver
+
+import (
+	"fmt"
+	"net/rpc"
+	"reflect"
+)
+
+// Statically typed counterpart of `Behaviour`
+type TypedBehaviour[Req, Resp any] interface {
+	Handle(serviceMethod string, seq uint64, req Req) (Resp, error)
+}
+
+// Statically typed counterpart of `GenServer`
+type TypedGenServer[Req, Resp any] interface {
+	Cast(serviceMethod string, req Req, reply *Resp, done chan *rpc.Call) *rpc.Call
+	Call(serviceMethod string, req Req) (Resp, error)
+	Close() error
+	Done() <-chan struct{}
+}
+
+func ListenTyped[Req, Resp any, T TypedBehaviour[Req, Resp]](f func(TypedGenServer[Req, Resp]) T, opts ...Option) T {
+	serv := NewGenServer(opts...)
+	behaviour := f(&typedGenServer[Req, Resp]{serv: serv})
+	go serv.Listen(typedBehaviour[Req, Resp]{behaviour: behaviour})
+	return behaviour
+}
+
+type typedGenServer[Req, Resp any] struct {
+	serv GenServer
+}
+
+var _ TypedGenServer[any, any] = (*typedGenServer[any, any])(nil)
+
+func (s *typedGenServer[Req, Resp]) Cast(serviceMethod string, req Req, reply *Resp, done chan *rpc.Call) *rpc.Call {
+	if reply == nil { // typed nil pointer must not reach the codec
+		return s.serv.Cast(serviceMethod, req, nil, done)
+	}
+	return s.serv.Cast(serviceMethod, req, reply, done)
+}
+
+func (s *typedGenServer[Req, Resp]) Call(serviceMethod string, req Req) (Resp, error) {
+	var reply Resp
+	err := s.serv.Call(serviceMethod, req, &reply)
+	return reply, err
+}
+
+func (s *typedGenServer[Req, Resp]) Close() error {
+	return s.serv.Close()
+}
+
+func (s *typedGenServer[Req, Resp]) Done() <-chan struct{} {
+	return s.serv.Done()
+}
+
+// Adapts `TypedBehaviour` to the untyped codec. Bodies always come from `typedGenServer`,
+// so they are guaranteed to be of type `Req`
+type typedBehaviour[Req, Resp any] struct {
+	behaviour TypedBehaviour[Req, Resp]
+}
+
+func (b typedBehaviour[Req, Resp]) Handle(serviceMethod string, seq uint64, body any) (any, error) {
+	req, _ := body.(Req) // `body` is nil when `Req` is an interface type and nil was sent
+	return b.behaviour.Handle(serviceMethod, seq, req)
+}
+
+// Returned by `Arg` when the body of a request is not of the expected type
+type ArgTypeError struct {
+	Expected reflect.Type
+	Actual   reflect.Type // nil if the body is nil
+}
+
+func (e *ArgTypeError) Error() string {
+	return fmt.Sprintf("invalid argument: expected %v, got %v", e.Expected, e.Actual)
+}
+
+// Extracts the argument of type `T` from the body of a request, so a handler can reject a mistyped request
+// instead of panicking on a type assertion. A nil body is accepted as the zero value of an interface `T`
+func Arg[T any](body any) (T, error) {
+	if v, ok := body.(T); ok {
+		return v, nil
+	}
+	var zero T
+	expected := reflect.TypeOf(&zero).Elem()
+	if body == nil && expected.Kind() == reflect.Interface {
+		return zero, nil
+	}
+	return zero, &ArgTypeError{Expected: expected, Actual: reflect.TypeOf(body)}
+}